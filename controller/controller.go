@@ -1,17 +1,26 @@
 package controller
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"runtime"
+	"sort"
+	"sync"
 
 	"github.com/pararang/pemilu2024/kpu"
 	"golang.org/x/sync/errgroup"
 )
 
+// VillageTree is a desa/kelurahan with its TPS, only fetched at depth kpu.LevelTPS.
+type VillageTree struct {
+	kpu.Location
+	TPS []kpu.Location `json:"tps,omitempty"`
+}
+
 type DistrictTree struct {
 	kpu.Location
-	Subdistrict []kpu.Location `json:"desa_kelurahan"`
+	Subdistrict []VillageTree `json:"desa_kelurahan"`
 }
 
 type CityTree struct {
@@ -25,10 +34,15 @@ type ProvinceTree struct {
 }
 
 type Controller struct {
-	sirekap *kpu.Sirekap
+	sirekap kpu.Source
+
+	// candidates and parties are the master data, fetched once
+	mu         sync.Mutex
+	candidates map[string]kpu.CandidatePair
+	parties    kpu.PartyRegistry
 }
 
-func NewController(sirekap *kpu.Sirekap) *Controller {
+func NewController(sirekap kpu.Source) *Controller {
 	return &Controller{
 		sirekap: sirekap,
 	}
@@ -55,21 +69,35 @@ func (c *Controller) maxGoroutine() uint64 {
 	return optimalMaxGoroutines
 }
 
-func (c *Controller) GetLocations(maxLoop uint) ([]ProvinceTree, error) {
+// GetLocations crawls the provinces down to depth, from kpu.LevelProvince to kpu.LevelTPS.
+// The overseas branch has its own shape, see GetLocationsOverseas.
+func (c *Controller) GetLocations(ctx context.Context, maxLoop uint, depth int) ([]ProvinceTree, error) {
+	if depth < kpu.LevelProvince || depth > kpu.LevelTPS {
+		return nil, fmt.Errorf("invalid depth %d, expect %d to %d", depth, kpu.LevelProvince, kpu.LevelTPS)
+	}
+
 	var provinces kpu.Locations
-	err := c.sirekap.FetchLocations(&provinces, "0")
+	err := c.sirekap.FetchLocations(ctx, &provinces, kpu.Nationwide)
 	if err != nil {
 		return nil, fmt.Errorf("error FetchLocations province: %w", err)
 	}
 
+	domestic := provinces[:0]
+	for _, province := range provinces {
+		if !province.Code.IsOverseas() {
+			domestic = append(domestic, province)
+		}
+	}
+	provinces = domestic
+
 	var (
 		locations    = make([]ProvinceTree, len(provinces))
 		maxGoroutine = len(provinces) //20 //c.maxGoroutine() //runtime.NumCPU()
 		sem          = make(chan struct{}, maxGoroutine)
 	)
 
-	// Create an error group
-	var eg errgroup.Group
+	// Create an error group, the first failure cancels the other provinces
+	eg, groupCtx := errgroup.WithContext(ctx)
 
 	for idxProv := 0; idxProv < len(provinces); idxProv++ {
 		if maxLoop > 0 && maxLoop == uint(idxProv) {
@@ -77,7 +105,16 @@ func (c *Controller) GetLocations(maxLoop uint) ([]ProvinceTree, error) {
 			break
 		}
 
-		sem <- struct{}{} // Acquire semaphore
+		select {
+		case sem <- struct{}{}: // Acquire semaphore
+		case <-groupCtx.Done():
+			// a failed province cancels groupCtx, otherwise the caller cancelled ctx:
+			// either way the tree is partial and must not be returned as complete
+			if err := eg.Wait(); err != nil {
+				return nil, err
+			}
+			return nil, ctx.Err()
+		}
 
 		idx := idxProv
 
@@ -87,7 +124,7 @@ func (c *Controller) GetLocations(maxLoop uint) ([]ProvinceTree, error) {
 			}()
 
 			var err error
-			locations[idx], err = c.getByProvince(provinces[idx])
+			locations[idx], err = c.getByProvince(groupCtx, provinces[idx], depth)
 			if err != nil {
 				return fmt.Errorf("error FetchLocations province %s (%s): %w",provinces[idx].Name, provinces[idx].Code, err)
 			}
@@ -100,14 +137,22 @@ func (c *Controller) GetLocations(maxLoop uint) ([]ProvinceTree, error) {
 		return nil, err
 	}
 
+	// the provinces still crawled after a cancellation may have stopped without an error of their own
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	return locations, nil
 }
 
-func (c *Controller) getByProvince(province kpu.Location) (provTree ProvinceTree, err error) {
+func (c *Controller) getByProvince(ctx context.Context, province kpu.Location, depth int) (provTree ProvinceTree, err error) {
 	provTree.Location = province
+	if depth < kpu.LevelRegency {
+		return provTree, nil
+	}
 
 	var cities kpu.Locations
-	err = c.sirekap.FetchLocations(&cities, province.Code)
+	err = c.sirekap.FetchLocations(ctx, &cities, province.Code)
 	if err != nil {
 		return provTree, fmt.Errorf("getCities %s: %w", province.Name, err)
 	}
@@ -115,9 +160,12 @@ func (c *Controller) getByProvince(province kpu.Location) (provTree ProvinceTree
 	provTree.Cities = make([]CityTree, len(cities))
 	for idxCity := 0; idxCity < len(cities); idxCity++ {
 		provTree.Cities[idxCity].Location = cities[idxCity]
+		if depth < kpu.LevelDistrict {
+			continue
+		}
 
 		var districts kpu.Locations
-		err = c.sirekap.FetchLocations(&districts, province.Code, cities[idxCity].Code)
+		err = c.sirekap.FetchLocations(ctx, &districts, cities[idxCity].Code)
 		if err != nil {
 			return provTree, fmt.Errorf("getDistricts %s: %w", cities[idxCity].Name, err)
 		}
@@ -125,16 +173,30 @@ func (c *Controller) getByProvince(province kpu.Location) (provTree ProvinceTree
 		provTree.Cities[idxCity].Districts = make([]DistrictTree, len(districts))
 		for idxDist := 0; idxDist < len(districts); idxDist++ {
 			provTree.Cities[idxCity].Districts[idxDist].Location = districts[idxDist]
+			if depth < kpu.LevelVillage {
+				continue
+			}
 
 			var subdistricts kpu.Locations
-			err = c.sirekap.FetchLocations(&subdistricts, province.Code, cities[idxCity].Code, districts[idxDist].Code)
+			err = c.sirekap.FetchLocations(ctx, &subdistricts, districts[idxDist].Code)
 			if err != nil {
 				return provTree, fmt.Errorf("getSubdistricts %s: %w", cities[idxCity].Name, err)
 			}
 
-			provTree.Cities[idxCity].Districts[idxDist].Subdistrict = make([]kpu.Location, len(subdistricts))
+			provTree.Cities[idxCity].Districts[idxDist].Subdistrict = make([]VillageTree, len(subdistricts))
 			for idxSubdist := 0; idxSubdist < len(subdistricts); idxSubdist++ {
-				provTree.Cities[idxCity].Districts[idxDist].Subdistrict[idxSubdist] = subdistricts[idxSubdist]
+				provTree.Cities[idxCity].Districts[idxDist].Subdistrict[idxSubdist].Location = subdistricts[idxSubdist]
+				if depth < kpu.LevelTPS {
+					continue
+				}
+
+				var tps kpu.Locations
+				err = c.sirekap.FetchLocations(ctx, &tps, subdistricts[idxSubdist].Code)
+				if err != nil {
+					return provTree, fmt.Errorf("getTPS %s: %w", subdistricts[idxSubdist].Name, err)
+				}
+
+				provTree.Cities[idxCity].Districts[idxDist].Subdistrict[idxSubdist].TPS = tps
 			}
 		}
 
@@ -143,41 +205,249 @@ func (c *Controller) getByProvince(province kpu.Location) (provTree ProvinceTree
 	return provTree, nil
 }
 
-type Votes struct {
-	Votes map[string]interface{} `json:"votes"`
-	Docs  []string               `json:"docs"`
+// GetTPSCodes returns the codes of every TPS under code, or code itself when it is a TPS.
+func (c *Controller) GetTPSCodes(ctx context.Context, code kpu.Code) ([]kpu.Code, error) {
+	if code.Level() == kpu.LevelTPS {
+		return []kpu.Code{code}, nil
+	}
+
+	var children kpu.Locations
+	err := c.sirekap.FetchLocations(ctx, &children, code)
+	if err != nil {
+		return nil, fmt.Errorf("error FetchLocations %s: %w", code, err)
+	}
+
+	var codes []kpu.Code
+	for _, child := range children {
+		tps, err := c.GetTPSCodes(ctx, child.Code)
+		if err != nil {
+			return nil, err
+		}
+
+		codes = append(codes, tps...)
+	}
+
+	return codes, nil
+}
+
+// OverseasPollingPlace is a TPSLN, a KSK or a POS.
+type OverseasPollingPlace struct {
+	kpu.Location
+	Method kpu.VotingMethod `json:"metode"`
+}
+
+// VotingMethodTree groups the polling places of a PPLN using one voting method.
+type VotingMethodTree struct {
+	kpu.Location
+	Method        kpu.VotingMethod       `json:"metode"`
+	PollingPlaces []OverseasPollingPlace `json:"tps,omitempty"`
 }
 
-func (c *Controller) GetVotes(codeTPS string) (Votes, error) {
-	data, err := c.sirekap.GetVotesByTPS(codeTPS)
+// PPLNTree is the election committee of an embassy or consulate.
+type PPLNTree struct {
+	kpu.Location
+	Methods []VotingMethodTree `json:"metode,omitempty"`
+}
+
+type CountryTree struct {
+	kpu.Location
+	PPLN []PPLNTree `json:"ppln,omitempty"`
+}
+
+type OverseasTree struct {
+	kpu.Location
+	Countries []CountryTree `json:"negara,omitempty"`
+}
+
+// GetLocationsOverseas crawls the Luar Negeri branch down to depth, the same levels as GetLocations:
+// kpu.LevelRegency are the countries, kpu.LevelDistrict the PPLN, kpu.LevelVillage the voting methods
+// and kpu.LevelTPS the polling places.
+func (c *Controller) GetLocationsOverseas(ctx context.Context, depth int) (OverseasTree, error) {
+	if depth < kpu.LevelProvince || depth > kpu.LevelTPS {
+		return OverseasTree{}, fmt.Errorf("invalid depth %d, expect %d to %d", depth, kpu.LevelProvince, kpu.LevelTPS)
+	}
+
+	var provinces kpu.Locations
+	err := c.sirekap.FetchLocations(ctx, &provinces, kpu.Nationwide)
 	if err != nil {
-		return Votes{}, fmt.Errorf("error on GetVotesByTPS: %w", err)
+		return OverseasTree{}, fmt.Errorf("error FetchLocations province: %w", err)
 	}
 
-	mapCand := map[string]string{
-		"100025": "AMIN",
-		"100026": "PAGI",
-		"100027": "GAMA",
+	var tree OverseasTree
+	for _, province := range provinces {
+		if province.Code == kpu.Overseas {
+			tree.Location = province
+		}
 	}
 
-	response := Votes{
-		Votes: make(map[string]interface{}),
+	if tree.Code != kpu.Overseas {
+		return OverseasTree{}, fmt.Errorf("error FetchLocations province: %w: no %s", kpu.ErrNotFound, kpu.Overseas)
+	}
+
+	if depth < kpu.LevelRegency {
+		return tree, nil
 	}
 
-	for code, votes := range data.Chart {
-		cand, ok := mapCand[code]
-		if !ok {
+	var countries kpu.Locations
+	err = c.sirekap.FetchLocations(ctx, &countries, tree.Code)
+	if err != nil {
+		return tree, fmt.Errorf("getCountries: %w", err)
+	}
+
+	tree.Countries = make([]CountryTree, len(countries))
+	for idxCountry, country := range countries {
+		tree.Countries[idxCountry].Location = country
+		if depth < kpu.LevelDistrict {
 			continue
 		}
 
-		response.Votes[cand] = votes
+		var ppln kpu.Locations
+		err = c.sirekap.FetchLocations(ctx, &ppln, country.Code)
+		if err != nil {
+			return tree, fmt.Errorf("getPPLN %s: %w", country.Name, err)
+		}
+
+		tree.Countries[idxCountry].PPLN = make([]PPLNTree, len(ppln))
+		for idxPPLN, committee := range ppln {
+			tree.Countries[idxCountry].PPLN[idxPPLN].Location = committee
+			if depth < kpu.LevelVillage {
+				continue
+			}
+
+			var methods kpu.Locations
+			err = c.sirekap.FetchLocations(ctx, &methods, committee.Code)
+			if err != nil {
+				return tree, fmt.Errorf("getVotingMethods %s: %w", committee.Name, err)
+			}
+
+			methodTrees := make([]VotingMethodTree, len(methods))
+			for idxMethod, method := range methods {
+				methodTrees[idxMethod] = VotingMethodTree{Location: method, Method: kpu.VotingMethodOf(method.Name)}
+				if depth < kpu.LevelTPS {
+					continue
+				}
+
+				var places kpu.Locations
+				err = c.sirekap.FetchLocations(ctx, &places, method.Code)
+				if err != nil {
+					return tree, fmt.Errorf("getPollingPlaces %s %s: %w", committee.Name, method.Name, err)
+				}
+
+				methodTrees[idxMethod].PollingPlaces = make([]OverseasPollingPlace, len(places))
+				for idxPlace, place := range places {
+					// a place named after its method wins over its group, should they ever disagree
+					votingMethod := kpu.VotingMethodOf(place.Name)
+					if votingMethod == "" {
+						votingMethod = methodTrees[idxMethod].Method
+					}
+
+					methodTrees[idxMethod].PollingPlaces[idxPlace] = OverseasPollingPlace{Location: place, Method: votingMethod}
+				}
+			}
+
+			tree.Countries[idxCountry].PPLN[idxPPLN].Methods = methodTrees
+		}
+	}
+
+	return tree, nil
+}
+
+type Votes struct {
+	Votes        map[string]interface{} `json:"votes"`
+	Docs         []string               `json:"docs"`
+	Administrasi *kpu.Administrasi      `json:"administrasi"`
+	PSU          *kpu.PSU               `json:"psu"`
+	// Turnout and InvalidRate are nil until the administrasi of the TPS is published
+	Turnout     *float64 `json:"turnout"`
+	InvalidRate *float64 `json:"invalid_rate"`
+}
+
+func (c *Controller) GetVotes(ctx context.Context, codeTPS kpu.Code) (Votes, error) {
+	data, err := c.sirekap.GetVotesByTPS(ctx, codeTPS)
+	if err != nil {
+		return Votes{}, fmt.Errorf("error on GetVotesByTPS: %w", err)
+	}
+
+	candidates := c.candidatesPresidential(ctx)
+
+	response := Votes{
+		Votes: make(map[string]interface{}),
+	}
+
+	for id, votes := range data.Chart {
+		response.Votes[CandidateLabel(candidates, id)] = votes
 	}
 
 	response.Docs = data.Images
+	response.PSU = data.PSU
+
+	if data.Administrasi != nil {
+		response.Administrasi = data.Administrasi
+
+		if turnout, ok := data.Administrasi.Turnout(); ok {
+			response.Turnout = &turnout
+		}
+
+		if invalidRate, ok := data.Administrasi.InvalidRate(); ok {
+			response.InvalidRate = &invalidRate
+		}
+	}
 
 	return response, nil
 }
 
+// candidatesPresidential returns the presidential candidate pairs, fetching them on first use only:
+// they do not change during the count. Without master data the votes are still returned, labelled
+// with kpu.DefaultCandidatesPresidential, and the master data is fetched again on the next call.
+func (c *Controller) candidatesPresidential(ctx context.Context) map[string]kpu.CandidatePair {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.candidates != nil {
+		return c.candidates
+	}
+
+	candidates, err := c.sirekap.GetCandidatesPresidential(ctx)
+	if err != nil {
+		log.Printf("no presidential candidates, use the default candidates: %v", err)
+		return kpu.DefaultCandidatesPresidential
+	}
+
+	c.candidates = candidates
+
+	return candidates
+}
+
+// partyRegistry returns the parties, fetching them on first use only like candidatesPresidential.
+// Without master data the votes are labelled with kpu.DefaultParties.
+func (c *Controller) partyRegistry(ctx context.Context) kpu.PartyRegistry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.parties != nil {
+		return c.parties
+	}
+
+	parties, err := c.sirekap.GetParties(ctx)
+	if err != nil {
+		log.Printf("no parties, use the default registry: %v", err)
+		return kpu.DefaultParties
+	}
+
+	c.parties = parties
+
+	return parties
+}
+
+// CandidateLabel is the name of the candidate pair, or its ID when it is missing from the master data.
+func CandidateLabel(candidates map[string]kpu.CandidatePair, id string) string {
+	if candidate, ok := candidates[id]; ok && candidate.Name != "" {
+		return candidate.Name
+	}
+
+	return id
+}
+
 type DataNationwide struct {
 	Votes   []Vote  `json:"votes"`
 	Progres Progres `json:"progres"`
@@ -189,21 +459,200 @@ type Progres struct {
 }
 
 type Vote struct {
-	LocationName   string  `json:"location_name"`
-	LocationLevel  int64   `json:"location_level"`
-	PSU            string  `json:"psu"`
-	Amin           int64   `json:"amin"`
-	Pagi           int64   `json:"pagi"`
-	Gama           int64   `json:"gama"`
-	Persen         float64 `json:"persen"`
-	StatusProgress bool    `json:"status_progress"`
+	LocationName  string `json:"location_name"`
+	LocationLevel int64  `json:"location_level"`
+	PSU           string `json:"psu"`
+	// Votes is keyed by candidate pair name
+	Votes          map[string]int64 `json:"votes"`
+	Persen         float64          `json:"persen"`
+	StatusProgress bool             `json:"status_progress"`
 }
 
-func (c *Controller) GetVotesNationwide() (kpu.ResponseDataPresidentialNationwide, error) {
-	data, err := c.sirekap.GetVotesPresidentialNationwide()
+func (c *Controller) GetVotesNationwide(ctx context.Context) (kpu.ResponseDataPresidentialNationwide, error) {
+	data, err := c.sirekap.GetVotesPresidentialNationwide(ctx)
 	if err != nil {
 		return kpu.ResponseDataPresidentialNationwide{}, fmt.Errorf("error on GetVotesByTPS: %w", err)
 	}
 	//TODO: transform to DataNationwide
 	return data, nil
 }
+
+// GetVotesByRegion returns the presidential votes of a region and of each of its children,
+// from the nationwide level (kpu.Nationwide) down to the village.
+func (c *Controller) GetVotesByRegion(ctx context.Context, code kpu.Code) (kpu.ResponseDataPresidentialRegion, error) {
+	data, err := c.sirekap.GetVotesPresidentialByRegion(ctx, code)
+	if err != nil {
+		return kpu.ResponseDataPresidentialRegion{}, fmt.Errorf("error on GetVotesPresidentialByRegion: %w", err)
+	}
+
+	return data, nil
+}
+
+// GetVotesLegislativeByRegion returns the DPR votes per party of a region and of each of its children,
+// from the nationwide level (kpu.Nationwide) down to the village.
+func (c *Controller) GetVotesLegislativeByRegion(ctx context.Context, code kpu.Code) (kpu.ResponseDataLegislativeRegion, error) {
+	data, err := c.sirekap.GetVotesLegislativeByRegion(ctx, code)
+	if err != nil {
+		return kpu.ResponseDataLegislativeRegion{}, fmt.Errorf("error on GetVotesLegislativeByRegion: %w", err)
+	}
+
+	return data, nil
+}
+
+func (c *Controller) GetVotesLegislativeByTPS(ctx context.Context, codeTPS kpu.Code) (kpu.ResponseDataLegislativeTPS, error) {
+	data, err := c.sirekap.GetVotesLegislativeByTPS(ctx, codeTPS)
+	if err != nil {
+		return kpu.ResponseDataLegislativeTPS{}, fmt.Errorf("error on GetVotesLegislativeByTPS: %w", err)
+	}
+
+	return data, nil
+}
+
+// GetVotesRegion returns the votes of any election for a region and each of its children,
+// e.g. the DPRD of a province or a regency.
+func (c *Controller) GetVotesRegion(ctx context.Context, election kpu.Election, code kpu.Code) (kpu.ResponseDataRegion, error) {
+	data, err := c.sirekap.GetVotesRegion(ctx, election, code)
+	if err != nil {
+		return kpu.ResponseDataRegion{}, fmt.Errorf("error on GetVotesRegion: %w", err)
+	}
+
+	return data, nil
+}
+
+type CandidateVotes struct {
+	ID     string `json:"id"`
+	Number int64  `json:"nomor_urut"`
+	Name   string `json:"nama"`
+	Votes  int64  `json:"votes"`
+}
+
+// PartyCandidates is the result of one party in a dapil, its caleg ranked by votes.
+type PartyCandidates struct {
+	ID         string           `json:"id"`
+	Name       string           `json:"nama"`
+	Total      int64            `json:"jml_suara_total"`
+	PartyVotes int64            `json:"jml_suara_partai"`
+	Candidates []CandidateVotes `json:"candidates"`
+}
+
+type DataDapil struct {
+	Ts      kpu.Time          `json:"ts"`
+	Dapil   string            `json:"dapil"`
+	Parties []PartyCandidates `json:"parties"`
+	Progres Progres           `json:"progres"`
+}
+
+// GetCandidatesDPRByDapil returns the DPR votes of a dapil per party in ballot order, and within each party
+// the caleg ranked by votes, the order seats go in under the open list. Ties keep the ballot order.
+func (c *Controller) GetCandidatesDPRByDapil(ctx context.Context, dapilCode string) (DataDapil, error) {
+	data, err := c.sirekap.GetVotesLegislativeByDapil(ctx, dapilCode)
+	if err != nil {
+		return DataDapil{}, fmt.Errorf("error on GetVotesLegislativeByDapil: %w", err)
+	}
+
+	candidates, err := c.sirekap.GetCandidatesDPR(ctx, dapilCode)
+	if err != nil {
+		return DataDapil{}, fmt.Errorf("error on GetCandidatesDPR: %w", err)
+	}
+
+	parties := c.partyRegistry(ctx)
+
+	votesByParty := make(map[string]int64, len(data.Chart))
+	for id, votes := range data.Chart {
+		votesByParty[id] = votes.Total
+	}
+
+	response := DataDapil{
+		Ts:      data.Ts,
+		Dapil:   dapilCode,
+		Parties: make([]PartyCandidates, 0, len(data.Chart)),
+		Progres: Progres{Total: data.Progres.Total, Progres: data.Progres.Progres},
+	}
+
+	for _, partyID := range parties.Columns(votesByParty) {
+		votes := data.Chart[partyID]
+		party := PartyCandidates{
+			ID:         partyID,
+			Name:       parties.Label(partyID),
+			Total:      votes.Total,
+			PartyVotes: votes.Party,
+			Candidates: make([]CandidateVotes, 0, len(votes.Candidates)),
+		}
+
+		for id, candidateVotes := range votes.Candidates {
+			// a caleg missing from the master data is kept, with its ID as the only label
+			candidate := candidates[partyID][id]
+			party.Candidates = append(party.Candidates, CandidateVotes{
+				ID:     id,
+				Number: candidate.Number,
+				Name:   candidate.Name,
+				Votes:  candidateVotes,
+			})
+		}
+
+		sort.Slice(party.Candidates, func(i, j int) bool {
+			a, b := party.Candidates[i], party.Candidates[j]
+			if a.Votes != b.Votes {
+				return a.Votes > b.Votes
+			}
+			if a.Number != b.Number {
+				return a.Number < b.Number
+			}
+			return a.ID < b.ID
+		})
+
+		response.Parties = append(response.Parties, party)
+	}
+
+	return response, nil
+}
+
+type DataDPD struct {
+	Ts         kpu.Time         `json:"ts"`
+	Candidates []CandidateVotes `json:"candidates"`
+	Progres    Progres          `json:"progres"`
+}
+
+// GetVotesDPD returns the DPD votes of a province or any region below it, per candidate in ballot order.
+func (c *Controller) GetVotesDPD(ctx context.Context, code kpu.Code) (DataDPD, error) {
+	data, err := c.sirekap.GetVotesDPDByRegion(ctx, code)
+	if err != nil {
+		return DataDPD{}, fmt.Errorf("error on GetVotesDPDByRegion: %w", err)
+	}
+
+	candidates, err := c.sirekap.GetCandidatesDPD(ctx, code.Province())
+	if err != nil {
+		return DataDPD{}, fmt.Errorf("error on GetCandidatesDPD: %w", err)
+	}
+
+	response := DataDPD{
+		Ts:         data.Ts,
+		Candidates: make([]CandidateVotes, 0, len(candidates)),
+		Progres:    Progres{Total: data.Progres.Total, Progres: data.Progres.Progres},
+	}
+
+	// every candidate on the ballot, counted yet or not
+	for _, id := range kpu.BallotDPD(candidates) {
+		response.Candidates = append(response.Candidates, CandidateVotes{
+			ID:     id,
+			Number: candidates[id].Number,
+			Name:   candidates[id].Name,
+			Votes:  data.Chart.Votes[id],
+		})
+	}
+
+	// a candidate missing from the master data is kept last, with its ID as the only label
+	var unknown []string
+	for id := range data.Chart.Votes {
+		if _, ok := candidates[id]; !ok {
+			unknown = append(unknown, id)
+		}
+	}
+	sort.Strings(unknown)
+
+	for _, id := range unknown {
+		response.Candidates = append(response.Candidates, CandidateVotes{ID: id, Votes: data.Chart.Votes[id]})
+	}
+
+	return response, nil
+}
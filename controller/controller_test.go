@@ -0,0 +1,193 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pararang/pemilu2024/kpu"
+	"github.com/pararang/pemilu2024/kpu/kputest"
+)
+
+// countingSource counts the master data requests made to its Source.
+type countingSource struct {
+	kpu.Source
+	parties int32
+}
+
+func (s *countingSource) GetParties(ctx context.Context) (kpu.PartyRegistry, error) {
+	atomic.AddInt32(&s.parties, 1)
+	return s.Source.GetParties(ctx)
+}
+
+func TestGetCandidatesDPRByDapilCachesParties(t *testing.T) {
+	srv := kputest.NewSyntheticServer(kputest.DefaultTree)
+	defer srv.Close()
+
+	source := &countingSource{Source: srv.Sirekap()}
+	controller := NewController(source)
+
+	for _, dapil := range []string{"1101", "1201", "1101"} {
+		data, err := controller.GetCandidatesDPRByDapil(context.Background(), dapil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data.Parties) == 0 || data.Parties[0].Name == data.Parties[0].ID {
+			t.Errorf("dapil %s: parties %+v, want them labelled from the registry", dapil, data.Parties)
+		}
+	}
+
+	if n := atomic.LoadInt32(&source.parties); n != 1 {
+		t.Errorf("GetParties called %d times, want once", n)
+	}
+}
+
+// tiedSource rewrites the caleg votes of one party of the dapil to tie two of its caleg.
+type tiedSource struct {
+	kpu.Source
+	party string
+}
+
+func (s tiedSource) GetVotesLegislativeByDapil(ctx context.Context, dapilCode string) (kpu.ResponseDataLegislativeDapil, error) {
+	data, err := s.Source.GetVotesLegislativeByDapil(ctx, dapilCode)
+	if err != nil {
+		return data, err
+	}
+
+	votes := data.Chart[s.party]
+	votes.Candidates = map[string]int64{s.party + "0003": 5, s.party + "0002": 9, s.party + "0001": 5}
+	data.Chart[s.party] = votes
+
+	return data, nil
+}
+
+func TestGetCandidatesDPRByDapilRanking(t *testing.T) {
+	srv := kputest.NewSyntheticServer(kputest.DefaultTree)
+	defer srv.Close()
+
+	ctx := context.Background()
+	sirekap := srv.Sirekap()
+
+	parties, err := sirekap.GetParties(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chart, err := sirekap.GetVotesLegislativeByDapil(ctx, "1201")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	votesByParty := make(map[string]int64, len(chart.Chart))
+	for id, votes := range chart.Chart {
+		votesByParty[id] = votes.Total
+	}
+	ballot := parties.Columns(votesByParty)
+	tied := ballot[0]
+
+	data, err := NewController(tiedSource{Source: sirekap, party: tied}).GetCandidatesDPRByDapil(ctx, "1201")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(data.Parties) != len(ballot) {
+		t.Fatalf("got %d parties, want %d", len(data.Parties), len(ballot))
+	}
+
+	for i, party := range data.Parties {
+		if party.ID != ballot[i] {
+			t.Errorf("party %d is %s, want %s in ballot order", i, party.ID, ballot[i])
+		}
+		if party.Total != chart.Chart[party.ID].Total || party.PartyVotes != chart.Chart[party.ID].Party {
+			t.Errorf("party %s: total %d, party votes %d, want the chart %+v", party.ID, party.Total, party.PartyVotes, chart.Chart[party.ID])
+		}
+
+		for j := 1; j < len(party.Candidates); j++ {
+			if party.Candidates[j-1].Votes < party.Candidates[j].Votes {
+				t.Errorf("party %s: caleg %+v ranked before %+v", party.ID, party.Candidates[j-1], party.Candidates[j])
+			}
+		}
+		for _, candidate := range party.Candidates {
+			if candidate.Name == "" || candidate.Number == 0 {
+				t.Errorf("party %s: caleg %+v not labelled from the master data", party.ID, candidate)
+			}
+		}
+	}
+
+	var got []string
+	for _, candidate := range data.Parties[0].Candidates {
+		got = append(got, candidate.ID)
+	}
+	want := []string{tied + "0002", tied + "0001", tied + "0003"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("tied caleg ranked %v, want %v: ties keep the ballot number order", got, want)
+	}
+}
+
+func TestGetLocationsOverseas(t *testing.T) {
+	srv := kputest.NewSyntheticServer(kputest.DefaultTree)
+	defer srv.Close()
+
+	ctx := context.Background()
+	controller := NewController(srv.Sirekap())
+
+	tree, err := controller.GetLocationsOverseas(ctx, kpu.LevelTPS)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tree.Code != kpu.Overseas || len(tree.Countries) != kputest.DefaultTree.Countries {
+		t.Fatalf("tree %s with %d countries", tree.Code, len(tree.Countries))
+	}
+
+	for _, country := range tree.Countries {
+		if len(country.PPLN) != 1 {
+			t.Fatalf("country %s has %d PPLN, want 1", country.Code, len(country.PPLN))
+		}
+
+		var methods []kpu.VotingMethod
+		for _, method := range country.PPLN[0].Methods {
+			methods = append(methods, method.Method)
+			if len(method.PollingPlaces) != kputest.DefaultTree.TPS {
+				t.Errorf("method %s has %d polling places, want %d", method.Name, len(method.PollingPlaces), kputest.DefaultTree.TPS)
+			}
+			for _, place := range method.PollingPlaces {
+				if place.Method != method.Method || place.Code.Parent() != method.Code {
+					t.Errorf("polling place %+v under %s %s", place, method.Code, method.Method)
+				}
+			}
+		}
+		if want := []kpu.VotingMethod{kpu.MethodTPS, kpu.MethodKSK, kpu.MethodPOS}; !reflect.DeepEqual(methods, want) {
+			t.Errorf("methods %v, want %v", methods, want)
+		}
+	}
+
+	// the countries only, without a request below them
+	tree, err = controller.GetLocationsOverseas(ctx, kpu.LevelRegency)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tree.Countries) != kputest.DefaultTree.Countries || tree.Countries[0].PPLN != nil {
+		t.Errorf("countries %+v, want them without PPLN", tree.Countries)
+	}
+
+	_, err = controller.GetLocationsOverseas(ctx, kpu.LevelTPS+1)
+	if err == nil {
+		t.Error("no error on an invalid depth")
+	}
+}
+
+func TestGetLocationsOverseasMissing(t *testing.T) {
+	tree := kputest.DefaultTree
+	tree.Countries = 0
+
+	srv := kputest.NewSyntheticServer(tree)
+	defer srv.Close()
+
+	_, err := NewController(srv.Sirekap()).GetLocationsOverseas(context.Background(), kpu.LevelTPS)
+	if !errors.Is(err, kpu.ErrNotFound) {
+		t.Errorf("error %v, want kpu.ErrNotFound without Luar Negeri", err)
+	}
+}
@@ -0,0 +1,194 @@
+package kpu
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ArchiveRecord is one raw Sirekap response as it was received.
+type ArchiveRecord struct {
+	FetchedAt  time.Time `json:"fetched_at"`
+	URL        string    `json:"url"`
+	StatusCode int       `json:"status"`
+	// NotModified is true when the body was served by ConditionalTransport after a 304.
+	NotModified bool   `json:"not_modified,omitempty"`
+	SHA256      string `json:"sha256"`
+	Body        []byte `json:"body"`
+}
+
+// ArchiveWriter appends raw responses to a gzip compressed JSON lines file, one file per run.
+// Every record is flushed as soon as it is written, so an interrupted run still leaves
+// a readable archive.
+type ArchiveWriter struct {
+	mu   sync.Mutex
+	file *os.File
+	gzip *gzip.Writer
+	enc  *json.Encoder
+}
+
+// CreateArchive creates a new archive at path. It fails if the file already exists,
+// an archive is never rewritten.
+func CreateArchive(path string) (*ArchiveWriter, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return nil, fmt.Errorf("error on create archive dir: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("error on create archive: %w", err)
+	}
+
+	gz := gzip.NewWriter(file)
+
+	return &ArchiveWriter{
+		file: file,
+		gzip: gz,
+		enc:  json.NewEncoder(gz),
+	}, nil
+}
+
+// WithArchive records every response received from Sirekap, including the failed ones, and every
+// response served from the disk cache, so that the archive of a run replays the whole run.
+func WithArchive(archive *ArchiveWriter) Option {
+	return func(s *Sirekap) {
+		s.archive = archive
+	}
+}
+
+func (a *ArchiveWriter) Write(record ArchiveRecord) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	err := a.enc.Encode(record)
+	if err != nil {
+		return fmt.Errorf("error on write archive record: %w", err)
+	}
+
+	err = a.gzip.Flush()
+	if err != nil {
+		return fmt.Errorf("error on flush archive: %w", err)
+	}
+
+	return nil
+}
+
+func (a *ArchiveWriter) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	err := a.gzip.Close()
+	if closeErr := a.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// record never fails the request, a response that can not be archived is still returned to the caller.
+func (a *ArchiveWriter) record(source string, statusCode int, notModified bool, body []byte) {
+	if a == nil {
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	err := a.Write(ArchiveRecord{
+		FetchedAt:   time.Now().UTC(),
+		URL:         source,
+		StatusCode:  statusCode,
+		NotModified: notModified,
+		SHA256:      hex.EncodeToString(sum[:]),
+		Body:        body,
+	})
+	if err != nil {
+		// the archive is evidence, losing records silently is worse than noisy logs
+		log.Printf("kpu: %s not archived: %v\n", source, err)
+	}
+}
+
+// ArchiveReader reads records back in the order they were written.
+type ArchiveReader struct {
+	file *os.File
+	gzip *gzip.Reader
+	dec  *json.Decoder
+}
+
+func OpenArchive(path string) (*ArchiveReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error on open archive: %w", err)
+	}
+
+	gz, err := gzip.NewReader(bufio.NewReader(file))
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error on open archive %s: %w", path, err)
+	}
+
+	return &ArchiveReader{
+		file: file,
+		gzip: gz,
+		dec:  json.NewDecoder(gz),
+	}, nil
+}
+
+// Next returns the next record, or io.EOF after the last one. An archive cut off by an
+// interrupted run ends at its last complete record.
+func (r *ArchiveReader) Next() (ArchiveRecord, error) {
+	var record ArchiveRecord
+	err := r.dec.Decode(&record)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return ArchiveRecord{}, io.EOF
+	}
+	if err != nil {
+		return ArchiveRecord{}, err
+	}
+
+	return record, nil
+}
+
+func (r *ArchiveReader) Close() error {
+	r.gzip.Close()
+	return r.file.Close()
+}
+
+// Verify reports whether the body still matches the hash recorded when it was fetched.
+func (r ArchiveRecord) Verify() bool {
+	sum := sha256.Sum256(r.Body)
+	return hex.EncodeToString(sum[:]) == r.SHA256
+}
+
+// ReadArchive calls fn for every record of the archive at path.
+func ReadArchive(path string, fn func(ArchiveRecord) error) error {
+	reader, err := OpenArchive(path)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error on read archive %s: %w", path, err)
+		}
+
+		err = fn(record)
+		if err != nil {
+			return err
+		}
+	}
+}
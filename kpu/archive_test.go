@@ -0,0 +1,96 @@
+package kpu
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestArchiveRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "raw", "sirekap.jsonl.gz")
+
+	archive, err := CreateArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bodies := [][]byte{[]byte(`{"ts":"2024-02-25 16:00:16"}`), nil, []byte(`{"progres":{"total":2}}`)}
+	for i, body := range bodies {
+		archive.record("https://sirekap/"+string(rune('a'+i)), 200, i == 2, body)
+	}
+
+	err = archive.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an archive is never rewritten
+	_, err = CreateArchive(path)
+	if err == nil {
+		t.Error("CreateArchive on an existing archive: want an error")
+	}
+
+	var records []ArchiveRecord
+	err = ReadArchive(path, func(record ArchiveRecord) error {
+		records = append(records, record)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != len(bodies) {
+		t.Fatalf("read %d records, want %d", len(records), len(bodies))
+	}
+	for i, record := range records {
+		if !bytes.Equal(record.Body, bodies[i]) || !record.Verify() {
+			t.Errorf("record %d: body %q verified %t, want %q", i, record.Body, record.Verify(), bodies[i])
+		}
+		if record.URL != "https://sirekap/"+string(rune('a'+i)) || record.NotModified != (i == 2) {
+			t.Errorf("record %d: %+v", i, record)
+		}
+	}
+
+	records[0].Body = []byte("tampered")
+	if records[0].Verify() {
+		t.Error("Verify() of a tampered body = true")
+	}
+}
+
+func TestArchiveInterrupted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sirekap.jsonl.gz")
+
+	archive, err := CreateArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive.record("https://sirekap/a", 200, false, []byte(`{}`))
+	archive.record("https://sirekap/b", 200, false, []byte(`{}`))
+
+	// a run killed before Close leaves the flushed records, without the gzip footer
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive.Close()
+
+	path = filepath.Join(filepath.Dir(path), "interrupted.jsonl.gz")
+	err = os.WriteFile(path, data, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var n int
+	err = ReadArchive(path, func(ArchiveRecord) error {
+		n++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("read %d records of an interrupted archive, want 2", n)
+	}
+}
@@ -0,0 +1,294 @@
+package kpu
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// C1ManifestName is the manifest file written at the root of a C1 mirror.
+const C1ManifestName = "manifest.json"
+
+// C1Image is one downloaded C1 scan, as recorded in the manifest.
+type C1Image struct {
+	TPS Code   `json:"tps"`
+	URL string `json:"url"`
+	// Path is relative to the mirror directory, e.g. 73/7371/737114/7371141006/7371141006002/scan.jpg
+	Path      string    `json:"path"`
+	SHA256    string    `json:"sha256"`
+	Size      int64     `json:"size"`
+	FetchedAt time.Time `json:"fetched_at"`
+	// Validators revalidate the scan on the next run.
+	Validators
+}
+
+// C1Stats counts what a C1Downloader did with the images of the requested TPS.
+type C1Stats struct {
+	Downloaded int
+	Unchanged  int
+	// Missing counts the TPS and images not published yet.
+	Missing int
+}
+
+// C1Downloader mirrors the C1 scans listed by the TPS responses under a directory laid out like
+// the Sirekap paths, one directory per TPS code.
+type C1Downloader struct {
+	source      Source
+	files       *Sirekap
+	dir         string
+	concurrency int
+
+	mu       sync.Mutex
+	manifest map[string]C1Image
+	stats    C1Stats
+}
+
+// NewC1Downloader reads the TPS responses from source and downloads the scans with files, which should have
+// no archive, disk cache or drift report: its retry policy and rate limit apply to the scans.
+// The manifest of dir, if any, is loaded so unchanged scans are revalidated instead of downloaded again.
+func NewC1Downloader(source Source, files *Sirekap, dir string, concurrency int) (*C1Downloader, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	d := &C1Downloader{
+		source:      source,
+		files:       files,
+		dir:         dir,
+		concurrency: concurrency,
+		manifest:    make(map[string]C1Image),
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, C1ManifestName))
+	if errors.Is(err, os.ErrNotExist) {
+		return d, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error on read C1 manifest: %w", err)
+	}
+
+	var images []C1Image
+	err = json.Unmarshal(data, &images)
+	if err != nil {
+		return nil, fmt.Errorf("error on decode C1 manifest: %w", err)
+	}
+
+	for _, image := range images {
+		d.manifest[image.Path] = image
+	}
+
+	return d, nil
+}
+
+// Download mirrors the scans of every TPS in codes, at most concurrency TPS at a time.
+// A TPS or a scan not published yet is counted as missing, any other failure stops the download;
+// the manifest keeps what was downloaded so far either way, see Save.
+func (d *C1Downloader) Download(ctx context.Context, codes []Code) (C1Stats, error) {
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(d.concurrency)
+
+	for _, code := range codes {
+		code := code
+		eg.Go(func() error {
+			return d.downloadTPS(ctx, code)
+		})
+	}
+
+	err := eg.Wait()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.stats, err
+}
+
+func (d *C1Downloader) downloadTPS(ctx context.Context, code Code) error {
+	votes, err := d.source.GetVotesByTPS(ctx, code)
+	if errors.Is(err, ErrNotFound) {
+		d.count(&d.stats.Missing)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error on GetVotesByTPS %s: %w", code, err)
+	}
+
+	for i, rawURL := range votes.Images {
+		// pages not scanned yet are null
+		if rawURL == "" {
+			continue
+		}
+
+		err = d.downloadImage(ctx, code, i, rawURL)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (d *C1Downloader) downloadImage(ctx context.Context, code Code, page int, rawURL string) error {
+	relPath := path.Join(append(code.PathSegments(), imageName(page, rawURL))...)
+
+	d.mu.Lock()
+	known, ok := d.manifest[relPath]
+	d.mu.Unlock()
+
+	// KPU may replace a scan at the same URL: the file on disk is only kept once the server
+	// confirms it, a damaged file or a new URL is downloaded in full
+	var validators Validators
+	intact := ok && known.URL == rawURL && d.intact(known)
+	if intact {
+		validators = known.Validators
+	}
+
+	body, latest, notModified, err := d.files.FetchFileIfModified(ctx, rawURL, validators)
+	if errors.Is(err, ErrNotFound) {
+		d.count(&d.stats.Missing)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error on download C1 %s: %w", rawURL, err)
+	}
+
+	sum := sha256.Sum256(body)
+
+	// without validators to revalidate with, the same bytes tell the scan did not change
+	if notModified || (intact && hex.EncodeToString(sum[:]) == known.SHA256) {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+
+		known.Validators = latest
+		d.manifest[relPath] = known
+		d.stats.Unchanged++
+
+		return nil
+	}
+
+	name := filepath.Join(d.dir, filepath.FromSlash(relPath))
+	err = writeFileAtomic(name, body)
+	if err != nil {
+		return fmt.Errorf("error on write C1 %s: %w", name, err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.manifest[relPath] = C1Image{
+		TPS:        code,
+		URL:        rawURL,
+		Path:       relPath,
+		SHA256:     hex.EncodeToString(sum[:]),
+		Size:       int64(len(body)),
+		FetchedAt:  time.Now().UTC(),
+		Validators: latest,
+	}
+	d.stats.Downloaded++
+
+	return nil
+}
+
+// intact checks the file of image is still the one recorded in the manifest.
+func (d *C1Downloader) intact(image C1Image) bool {
+	name := filepath.Join(d.dir, filepath.FromSlash(image.Path))
+
+	info, err := os.Stat(name)
+	if err != nil || info.Size() != image.Size {
+		return false
+	}
+
+	file, err := os.Open(name)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return false
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)) == image.SHA256
+}
+
+func (d *C1Downloader) count(counter *int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	*counter++
+}
+
+// Save writes the manifest, sorted by path.
+func (d *C1Downloader) Save() error {
+	d.mu.Lock()
+	images := make([]C1Image, 0, len(d.manifest))
+	for _, image := range d.manifest {
+		images = append(images, image)
+	}
+	d.mu.Unlock()
+
+	sort.Slice(images, func(i, j int) bool {
+		return images[i].Path < images[j].Path
+	})
+
+	data, err := json.MarshalIndent(images, "", "\t")
+	if err != nil {
+		return fmt.Errorf("error on encode C1 manifest: %w", err)
+	}
+
+	err = writeFileAtomic(filepath.Join(d.dir, C1ManifestName), data)
+	if err != nil {
+		return fmt.Errorf("error on write C1 manifest: %w", err)
+	}
+
+	return nil
+}
+
+// imageName is the file name of a scan: the last segment of its URL, prefixed by its page
+// so two pages never collide.
+func imageName(page int, rawURL string) string {
+	name := "c1.jpg"
+	if parsed, err := url.Parse(rawURL); err == nil && path.Base(parsed.Path) != "." && path.Base(parsed.Path) != "/" {
+		name = path.Base(parsed.Path)
+	}
+
+	return fmt.Sprintf("%d_%s", page+1, name)
+}
+
+func writeFileAtomic(name string, data []byte) error {
+	err := os.MkdirAll(filepath.Dir(name), 0755)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".tmp*")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), name)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+
+	return err
+}
@@ -0,0 +1,207 @@
+package kpu_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pararang/pemilu2024/kpu"
+)
+
+const c1TPS kpu.Code = "1101012001001"
+
+// scanServer serves the TPS response of c1TPS, listing two scans and a page not uploaded yet,
+// and the scans themselves with an ETag when etags is set.
+type scanServer struct {
+	*httptest.Server
+	etags bool
+
+	mu          sync.Mutex
+	scans       map[string]string
+	downloads   int
+	notModified int
+}
+
+func newScanServer(t *testing.T, etags bool) *scanServer {
+	s := &scanServer{
+		etags: etags,
+		scans: map[string]string{"/scan/1.jpg": "page 1", "/scan/2.jpg": "page 2"},
+	}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/pemilu/hhcw/ppwp/"+strings.Join(c1TPS.PathSegments(), "/")+".json" {
+			fmt.Fprintf(w, `{"images": [%q, %q, %q], "status_suara": true}`, s.URL+"/scan/1.jpg", s.URL+"/scan/2.jpg", s.URL+"/scan/3.jpg")
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		scan, ok := s.scans[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		if s.etags {
+			sum := sha256.Sum256([]byte(scan))
+			etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+			if r.Header.Get("If-None-Match") == etag {
+				s.notModified++
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", etag)
+		}
+
+		s.downloads++
+		fmt.Fprint(w, scan)
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *scanServer) replace(path, scan string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.scans[path] = scan
+}
+
+func (s *scanServer) counts() (downloads, notModified int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.downloads, s.notModified
+}
+
+// downloadC1 runs one downloader over the mirror dir, loading and saving its manifest like fetchC1.
+func downloadC1(t *testing.T, srv *scanServer, dir string) kpu.C1Stats {
+	t.Helper()
+
+	sirekap := kpu.NewSirekap(srv.Client(), kpu.WithHost(srv.URL), kpu.WithRateLimit(kpu.RateLimit{}), kpu.WithRetry(kpu.NoRetry))
+
+	downloader, err := kpu.NewC1Downloader(sirekap, sirekap, dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := downloader.Download(context.Background(), []kpu.Code{c1TPS, "1101012001002"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = downloader.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return stats
+}
+
+func readScan(t *testing.T, dir string, page int) string {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join(append([]string{dir}, append(c1TPS.PathSegments(), fmt.Sprintf("%d_%d.jpg", page, page))...)...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(data)
+}
+
+func TestC1DownloaderRevalidates(t *testing.T) {
+	srv := newScanServer(t, true)
+	dir := t.TempDir()
+
+	// the second TPS and the third page are not published
+	stats := downloadC1(t, srv, dir)
+	if want := (kpu.C1Stats{Downloaded: 2, Missing: 2}); stats != want {
+		t.Fatalf("first run %+v, want %+v", stats, want)
+	}
+	if got := readScan(t, dir, 1); got != "page 1" {
+		t.Errorf("page 1 is %q", got)
+	}
+
+	stats = downloadC1(t, srv, dir)
+	if want := (kpu.C1Stats{Unchanged: 2, Missing: 2}); stats != want {
+		t.Errorf("second run %+v, want %+v", stats, want)
+	}
+	if downloads, notModified := srv.counts(); downloads != 2 || notModified != 2 {
+		t.Errorf("%d downloads and %d 304, want the scans revalidated rather than downloaded again", downloads, notModified)
+	}
+
+	// KPU replaces a scan under the same URL
+	srv.replace("/scan/2.jpg", "page 2 rescanned")
+
+	stats = downloadC1(t, srv, dir)
+	if want := (kpu.C1Stats{Downloaded: 1, Unchanged: 1, Missing: 2}); stats != want {
+		t.Errorf("run after the replacement %+v, want %+v", stats, want)
+	}
+	if got := readScan(t, dir, 2); got != "page 2 rescanned" {
+		t.Errorf("page 2 is %q, want the replaced scan", got)
+	}
+
+	manifest, err := os.ReadFile(filepath.Join(dir, kpu.C1ManifestName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte("page 2 rescanned"))
+	if !strings.Contains(string(manifest), hex.EncodeToString(sum[:])) || !strings.Contains(string(manifest), `"etag"`) {
+		t.Errorf("manifest misses the checksum or the ETag of the replaced scan:\n%s", manifest)
+	}
+}
+
+func TestC1DownloaderDamagedFile(t *testing.T) {
+	srv := newScanServer(t, true)
+	dir := t.TempDir()
+
+	downloadC1(t, srv, dir)
+
+	name := filepath.Join(append([]string{dir}, append(c1TPS.PathSegments(), "1_1.jpg")...)...)
+	err := os.WriteFile(name, []byte("page 0"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a damaged file is downloaded in full, a 304 would keep it
+	stats := downloadC1(t, srv, dir)
+	if want := (kpu.C1Stats{Downloaded: 1, Unchanged: 1, Missing: 2}); stats != want {
+		t.Errorf("stats %+v, want %+v", stats, want)
+	}
+	if got := readScan(t, dir, 1); got != "page 1" {
+		t.Errorf("page 1 is %q, want it downloaded again", got)
+	}
+}
+
+func TestC1DownloaderWithoutValidators(t *testing.T) {
+	srv := newScanServer(t, false)
+	dir := t.TempDir()
+
+	downloadC1(t, srv, dir)
+
+	// nothing to revalidate with: the scans are downloaded again and compared
+	stats := downloadC1(t, srv, dir)
+	if want := (kpu.C1Stats{Unchanged: 2, Missing: 2}); stats != want {
+		t.Errorf("unchanged scans %+v, want %+v", stats, want)
+	}
+
+	srv.replace("/scan/1.jpg", "page 1 rescanned")
+
+	stats = downloadC1(t, srv, dir)
+	if want := (kpu.C1Stats{Downloaded: 1, Unchanged: 1, Missing: 2}); stats != want {
+		t.Errorf("replaced scan %+v, want %+v", stats, want)
+	}
+	if got := readScan(t, dir, 1); got != "page 1 rescanned" {
+		t.Errorf("page 1 is %q, want the replaced scan", got)
+	}
+}
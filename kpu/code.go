@@ -0,0 +1,144 @@
+package kpu
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Code is a location code, each level extending the code of its parent: 2 digits for a province,
+// 4 for a regency/city, 6 for a district, 10 for a village and 13 for a TPS, e.g. 7371141006002.
+// The empty code is the nationwide root.
+type Code string
+
+const Nationwide Code = ""
+
+// Location levels, as in the tingkat field of the location lists.
+const (
+	LevelNationwide = iota
+	LevelProvince
+	LevelRegency
+	LevelDistrict
+	LevelVillage
+	LevelTPS
+)
+
+// codeLengths are the code lengths per level.
+var codeLengths = []int{0, 2, 4, 6, 10, 13}
+
+// ParseCode validates s as a code of any level. "0", the code Sirekap uses for the root, is Nationwide.
+func ParseCode(s string) (Code, error) {
+	if s == "0" {
+		return Nationwide, nil
+	}
+
+	code := Code(s)
+	if err := code.Validate(); err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+// Validate checks c has only digits and the length of one of the levels.
+func (c Code) Validate() error {
+	for _, r := range c {
+		if r < '0' || r > '9' {
+			return fmt.Errorf("%w: %q, expect digits only", ErrInvalidCode, string(c))
+		}
+	}
+
+	if c.Level() < 0 {
+		return fmt.Errorf("%w: %q, expect 2, 4, 6, 10 or 13 digits", ErrInvalidCode, string(c))
+	}
+
+	return nil
+}
+
+// Level returns the level of c, from LevelNationwide to LevelTPS, or -1 when its length matches no level.
+func (c Code) Level() int {
+	for level, length := range codeLengths {
+		if len(c) == length {
+			return level
+		}
+	}
+
+	return -1
+}
+
+// Parent returns the code of the location c is part of, Nationwide for a province.
+func (c Code) Parent() Code {
+	level := c.Level()
+	if level <= LevelProvince {
+		return Nationwide
+	}
+
+	return c[:codeLengths[level-1]]
+}
+
+// Ancestors returns the codes of every location above c, province first, not including Nationwide.
+func (c Code) Ancestors() []Code {
+	level := c.Level()
+	if level <= LevelProvince {
+		return nil
+	}
+
+	ancestors := make([]Code, 0, level-1)
+	for _, length := range codeLengths[1:level] {
+		ancestors = append(ancestors, c[:length])
+	}
+
+	return ancestors
+}
+
+// PathSegments returns the Sirekap path of c, the codes of its ancestors then itself,
+// e.g. 73/7371/737114 for 737114.
+func (c Code) PathSegments() []string {
+	if c == Nationwide {
+		return nil
+	}
+
+	segments := make([]string, 0, c.Level())
+	for _, ancestor := range c.Ancestors() {
+		segments = append(segments, string(ancestor))
+	}
+
+	return append(segments, string(c))
+}
+
+// Province returns the province code of c, Nationwide for Nationwide.
+func (c Code) Province() Code {
+	if len(c) < codeLengths[LevelProvince] {
+		return Nationwide
+	}
+
+	return c[:codeLengths[LevelProvince]]
+}
+
+func (c Code) String() string {
+	return string(c)
+}
+
+// segments validates c is between levels from and to before returning its path segments.
+func (c Code) segments(from, to int) ([]string, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	if level := c.Level(); level < from || level > to {
+		lengths := make([]string, 0, to-from+1)
+		for _, length := range codeLengths[from : to+1] {
+			if length > 0 {
+				lengths = append(lengths, fmt.Sprint(length))
+			}
+		}
+
+		expect := strings.Join(lengths, ", ") + " digits"
+		if from == LevelNationwide {
+			expect += " or nationwide"
+		}
+
+		return nil, fmt.Errorf("%w: %q, expect %s", ErrInvalidCode, string(c), expect)
+	}
+
+	return c.PathSegments(), nil
+}
@@ -0,0 +1,78 @@
+package kpu
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseCode(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Code
+		level   int
+		wantErr bool
+	}{
+		{in: "0", want: Nationwide, level: LevelNationwide},
+		{in: "73", want: "73", level: LevelProvince},
+		{in: "7371", want: "7371", level: LevelRegency},
+		{in: "737114", want: "737114", level: LevelDistrict},
+		{in: "7371141006", want: "7371141006", level: LevelVillage},
+		{in: "7371141006002", want: "7371141006002", level: LevelTPS},
+		{in: "737", wantErr: true},
+		{in: "73a1", wantErr: true},
+		{in: "73711410060021", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseCode(tt.in)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidCode) {
+				t.Errorf("ParseCode(%q) error = %v, want %v", tt.in, err, ErrInvalidCode)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseCode(%q) error = %v", tt.in, err)
+			continue
+		}
+
+		if got != tt.want || got.Level() != tt.level {
+			t.Errorf("ParseCode(%q) = %q level %d, want %q level %d", tt.in, got, got.Level(), tt.want, tt.level)
+		}
+	}
+}
+
+func TestCodeHierarchy(t *testing.T) {
+	code := Code("7371141006002")
+
+	if got := code.Parent(); got != "7371141006" {
+		t.Errorf("Parent() = %q", got)
+	}
+	if got := Code("73").Parent(); got != Nationwide {
+		t.Errorf("Parent() of a province = %q, want nationwide", got)
+	}
+	if got := code.Province(); got != "73" {
+		t.Errorf("Province() = %q", got)
+	}
+
+	want := []string{"73", "7371", "737114", "7371141006", "7371141006002"}
+	if got := code.PathSegments(); !reflect.DeepEqual(got, want) {
+		t.Errorf("PathSegments() = %v, want %v", got, want)
+	}
+	if got := Nationwide.PathSegments(); got != nil {
+		t.Errorf("PathSegments() of nationwide = %v, want none", got)
+	}
+}
+
+func TestCodeSegments(t *testing.T) {
+	_, err := Code("7371141006002").segments(LevelNationwide, LevelVillage)
+	if !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("segments of a TPS up to the village level: error = %v, want %v", err, ErrInvalidCode)
+	}
+
+	segments, err := Code("7371").segments(LevelNationwide, LevelVillage)
+	if err != nil || !reflect.DeepEqual(segments, []string{"73", "7371"}) {
+		t.Errorf("segments = %v, %v", segments, err)
+	}
+}
@@ -0,0 +1,206 @@
+package kpu
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// HeaderNotModified is set on responses that ConditionalTransport rebuilt from its cache after a 304.
+const HeaderNotModified = "X-Sirekap-Not-Modified"
+
+// ConditionalTransport remembers the ETag / Last-Modified of every GET response and revalidates
+// with If-None-Match / If-Modified-Since. A 304 is turned into a 200 carrying the cached body,
+// so the callers never see the difference except through NotModified.
+//
+// Wrap the logging transport with it to log the real 304s:
+//
+//	kpu.NewConditionalTransport(&loggingTransport{Transport: transport})
+type ConditionalTransport struct {
+	Transport http.RoundTripper
+
+	mu          sync.Mutex
+	entries     map[string]conditionalEntry
+	notModified map[string]bool
+}
+
+type conditionalEntry struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	ContentType  string `json:"content_type,omitempty"`
+	Body         []byte `json:"body"`
+}
+
+func NewConditionalTransport(transport http.RoundTripper) *ConditionalTransport {
+	return &ConditionalTransport{
+		Transport:   transport,
+		entries:     make(map[string]conditionalEntry),
+		notModified: make(map[string]bool),
+	}
+}
+
+func (t *ConditionalTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return t.Transport.RoundTrip(req)
+	}
+
+	key := req.URL.String()
+
+	t.mu.Lock()
+	entry, cached := t.entries[key]
+	t.mu.Unlock()
+
+	if cached {
+		req = req.Clone(req.Context())
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	resp, err := t.Transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached:
+		resp.Body.Close()
+		t.setNotModified(key, true)
+		return entry.response(req), nil
+	case resp.StatusCode == http.StatusOK:
+		t.setNotModified(key, false)
+		return t.store(key, resp)
+	default:
+		return resp, nil
+	}
+}
+
+// NotModified reports whether the last response for rawURL was served from the cache after a 304.
+// A nil transport, i.e. a run without conditional cache, never serves one.
+func (t *ConditionalTransport) NotModified(rawURL string) bool {
+	if t == nil {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.notModified[rawURL]
+}
+
+// Load reads validators saved by Save in a previous run. A missing file is not an error.
+func (t *ConditionalTransport) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error on read conditional cache: %w", err)
+	}
+
+	entries := make(map[string]conditionalEntry)
+	err = json.Unmarshal(data, &entries)
+	if err != nil {
+		return fmt.Errorf("error on decode conditional cache %s: %w", path, err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.entries = entries
+
+	return nil
+}
+
+// Save writes the validators and bodies so the next run can revalidate instead of downloading.
+func (t *ConditionalTransport) Save(path string) error {
+	t.mu.Lock()
+	data, err := json.Marshal(t.entries)
+	t.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("error on encode conditional cache: %w", err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return fmt.Errorf("error on create conditional cache dir: %w", err)
+	}
+
+	err = os.WriteFile(path, data, 0644)
+	if err != nil {
+		return fmt.Errorf("error on write conditional cache: %w", err)
+	}
+
+	return nil
+}
+
+func (t *ConditionalTransport) setNotModified(key string, notModified bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.notModified[key] = notModified
+}
+
+func (t *ConditionalTransport) store(key string, resp *http.Response) (*http.Response, error) {
+	entry := conditionalEntry{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		ContentType:  resp.Header.Get("Content-Type"),
+	}
+
+	if entry.ETag == "" && entry.LastModified == "" {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	entry.Body = body
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	t.mu.Lock()
+	t.entries[key] = entry
+	t.mu.Unlock()
+
+	return resp, nil
+}
+
+func (e conditionalEntry) response(req *http.Request) *http.Response {
+	header := make(http.Header)
+	header.Set(HeaderNotModified, "1")
+	header.Set("Content-Length", strconv.Itoa(len(e.Body)))
+	if e.ContentType != "" {
+		header.Set("Content-Type", e.ContentType)
+	}
+	if e.ETag != "" {
+		header.Set("ETag", e.ETag)
+	}
+	if e.LastModified != "" {
+		header.Set("Last-Modified", e.LastModified)
+	}
+
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,90 @@
+package kpu
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestConditionalTransport(t *testing.T) {
+	var notModified int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/plain.json" {
+			w.Write([]byte(`{"plain":true}`))
+			return
+		}
+
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			atomic.AddInt32(&notModified, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte(`{"ts":"2024-02-25 16:00:16"}`))
+	}))
+	defer srv.Close()
+
+	get := func(transport *ConditionalTransport, path string) (string, *http.Response) {
+		t.Helper()
+
+		resp, err := (&http.Client{Transport: transport}).Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return string(body), resp
+	}
+
+	transport := NewConditionalTransport(http.DefaultTransport)
+	first, _ := get(transport, "/ppwp.json")
+	if transport.NotModified(srv.URL + "/ppwp.json") {
+		t.Error("NotModified after the first download = true")
+	}
+
+	// the 304 is served as a 200 with the body of the first response
+	second, resp := get(transport, "/ppwp.json")
+	if resp.StatusCode != http.StatusOK || second != first || resp.Header.Get(HeaderNotModified) == "" {
+		t.Errorf("revalidated response: %d %q, want 200 %q", resp.StatusCode, second, first)
+	}
+	if !transport.NotModified(srv.URL+"/ppwp.json") || atomic.LoadInt32(&notModified) != 1 {
+		t.Error("NotModified after a 304 = false")
+	}
+
+	// a response without validator is not kept
+	get(transport, "/plain.json")
+	if _, ok := transport.entries[srv.URL+"/plain.json"]; ok {
+		t.Error("response without ETag nor Last-Modified kept")
+	}
+
+	// the validators survive the run
+	path := filepath.Join(t.TempDir(), "conditional.json")
+	if err := transport.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	next := NewConditionalTransport(http.DefaultTransport)
+	if err := next.Load(path); err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := get(next, "/ppwp.json")
+	if body != first || !next.NotModified(srv.URL+"/ppwp.json") {
+		t.Errorf("response of the next run: %q, want the cached %q", body, first)
+	}
+}
+
+func TestConditionalTransportNil(t *testing.T) {
+	var transport *ConditionalTransport
+	if transport.NotModified("https://sirekap-obj-data.kpu.go.id/pemilu/hhcw/ppwp.json") {
+		t.Error("NotModified without conditional cache = true")
+	}
+}
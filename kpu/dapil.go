@@ -0,0 +1,87 @@
+package kpu
+
+import (
+	"context"
+	"fmt"
+)
+
+// Dapil is a DPR electoral district: a province, or a group of regencies within a province.
+type Dapil struct {
+	ID   int64  `json:"id"`
+	Code string `json:"kode"`
+	Name string `json:"nama"`
+	// Regions are the codes of the regencies of the dapil.
+	Regions []Code `json:"wilayah"`
+}
+
+// GetDapilsDPR returns every DPR dapil, https://sirekap-obj-data.kpu.go.id/wilayah/pemilu/pdpr/dapil.json
+func (s *Sirekap) GetDapilsDPR(ctx context.Context) ([]Dapil, error) {
+	var dapils []Dapil
+	err := s.get(ctx, &dapils, "wilayah/pemilu/pdpr", "dapil")
+	if err != nil {
+		return nil, fmt.Errorf("error on get dapil DPR: %w", err)
+	}
+
+	return dapils, nil
+}
+
+// CandidateDPR is a DPR caleg, published with the same master data as a DPD candidate.
+type CandidateDPR = CandidateDPD
+
+// GetCandidatesDPR returns the caleg of a dapil keyed by party ID, then by caleg ID, e.g.
+// https://sirekap-obj-data.kpu.go.id/pemilu/caleg/partai/7301.json
+func (s *Sirekap) GetCandidatesDPR(ctx context.Context, dapilCode string) (map[string]map[string]CandidateDPR, error) {
+	if err := validDapil(dapilCode); err != nil {
+		return nil, err
+	}
+
+	candidates := make(map[string]map[string]CandidateDPR)
+	err := s.get(ctx, &candidates, "pemilu/caleg/partai", dapilCode)
+	if err != nil {
+		return nil, fmt.Errorf("error on get candidates DPR: %w", err)
+	}
+
+	return candidates, nil
+}
+
+// ResponseDataLegislativeDapil is the DPR result of a dapil with the votes of every caleg. Chart is
+// keyed by party ID, Table by the code of the regencies of the dapil and then by party ID.
+type ResponseDataLegislativeDapil struct {
+	Ts      Time                                `json:"ts"`
+	PSU     PSU                                 `json:"psu"`
+	Mode    string                              `json:"mode"`
+	Chart   map[string]PartyVotesTPS            `json:"chart"`
+	Table   map[string]map[string]PartyVotesTPS `json:"table"`
+	Progres Progres                             `json:"progres"`
+}
+
+// GetVotesLegislativeByDapil returns the DPR party and caleg votes of a dapil, e.g.
+// https://sirekap-obj-data.kpu.go.id/pemilu/hhcw/pdpr/dapil/7301.json
+func (s *Sirekap) GetVotesLegislativeByDapil(ctx context.Context, dapilCode string) (ResponseDataLegislativeDapil, error) {
+	if err := validDapil(dapilCode); err != nil {
+		return ResponseDataLegislativeDapil{}, err
+	}
+
+	var votes ResponseDataLegislativeDapil
+	err := s.fetchVotes(ctx, &votes, "pdpr", "dapil", dapilCode)
+	if err != nil {
+		return ResponseDataLegislativeDapil{}, fmt.Errorf("error on fetchVotes: %w", err)
+	}
+
+	return votes, nil
+}
+
+// validDapil checks a dapil code: the province code followed by the number of the dapil, e.g. 7301.
+func validDapil(code string) error {
+	if len(code) != 4 {
+		return fmt.Errorf("%w: dapil code %q, expect 4 digits", ErrInvalidCode, code)
+	}
+
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return fmt.Errorf("%w: dapil code %q, expect 4 digits", ErrInvalidCode, code)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,221 @@
+package kpu
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DiskCache keeps Sirekap response bodies on disk, keyed by URL.
+// Location lists practically never change during the count, so they get a much longer TTL than votes.
+type DiskCache struct {
+	dir         string
+	locationTTL time.Duration
+	voteTTL     time.Duration
+	maxBytes    int64
+
+	mu    sync.Mutex
+	size  int64
+	files map[string]cachedFile
+}
+
+type DiskCacheConfig struct {
+	Dir string
+	// LocationTTL applies to wilayah/... and to candidate master data, VoteTTL to pemilu/hhcw/... responses.
+	// Zero or less disables the cache for that kind of endpoint.
+	LocationTTL time.Duration
+	VoteTTL     time.Duration
+	// MaxBytes caps the total size of the cache, the oldest entries are evicted first. Zero means no cap.
+	MaxBytes int64
+}
+
+var DefaultDiskCacheConfig = DiskCacheConfig{
+	Dir:         ".cache/sirekap",
+	LocationTTL: 7 * 24 * time.Hour,
+	VoteTTL:     10 * time.Minute,
+	MaxBytes:    1 << 30,
+}
+
+type cachedFile struct {
+	size    int64
+	storeAt time.Time
+}
+
+const diskCacheExt = ".json"
+
+// OpenDiskCache creates the cache directory if needed and indexes the entries already in it.
+func OpenDiskCache(config DiskCacheConfig) (*DiskCache, error) {
+	err := os.MkdirAll(config.Dir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("error on create cache dir: %w", err)
+	}
+
+	entries, err := os.ReadDir(config.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("error on read cache dir: %w", err)
+	}
+
+	c := &DiskCache{
+		dir:         config.Dir,
+		locationTTL: config.LocationTTL,
+		voteTTL:     config.VoteTTL,
+		maxBytes:    config.MaxBytes,
+		files:       make(map[string]cachedFile, len(entries)),
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), diskCacheExt) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		c.files[entry.Name()] = cachedFile{size: info.Size(), storeAt: info.ModTime()}
+		c.size += info.Size()
+	}
+
+	return c, nil
+}
+
+// WithDiskCache serves responses from cache while they are fresh and stores every new response in it.
+func WithDiskCache(cache *DiskCache) Option {
+	return func(s *Sirekap) {
+		s.cache = cache
+	}
+}
+
+// Purge removes every cached response.
+func (c *DiskCache) Purge() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for name := range c.files {
+		err := os.Remove(filepath.Join(c.dir, name))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error on purge cache: %w", err)
+		}
+		delete(c.files, name)
+	}
+
+	c.size = 0
+
+	return nil
+}
+
+// Size returns the number of cached responses and their total size in bytes.
+func (c *DiskCache) Size() (int, int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.files), c.size
+}
+
+func (c *DiskCache) get(basePath, source string) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	ttl := c.locationTTL
+	if strings.HasPrefix(basePath, "pemilu/hhcw") {
+		ttl = c.voteTTL
+	}
+
+	if ttl <= 0 {
+		return nil, false
+	}
+
+	name := cacheFileName(source)
+
+	c.mu.Lock()
+	file, ok := c.files[name]
+	c.mu.Unlock()
+
+	if !ok || time.Since(file.storeAt) > ttl {
+		return nil, false
+	}
+
+	body, err := os.ReadFile(filepath.Join(c.dir, name))
+	if err != nil {
+		return nil, false
+	}
+
+	return body, true
+}
+
+// put never fails the request, a response that can not be cached is just fetched again next time.
+func (c *DiskCache) put(source string, body []byte) {
+	if c == nil {
+		return
+	}
+
+	name := cacheFileName(source)
+
+	tmp, err := os.CreateTemp(c.dir, name+".tmp*")
+	if err != nil {
+		return
+	}
+
+	_, err = tmp.Write(body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(c.dir, name))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.size -= c.files[name].size
+	c.files[name] = cachedFile{size: int64(len(body)), storeAt: time.Now()}
+	c.size += int64(len(body))
+
+	if c.maxBytes > 0 && c.size > c.maxBytes {
+		c.evict()
+	}
+}
+
+// evict removes the oldest entries until the cache is back under 90% of its cap,
+// so a full cache does not have to sort its index on every put.
+func (c *DiskCache) evict() {
+	names := make([]string, 0, len(c.files))
+	for name := range c.files {
+		names = append(names, name)
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		return c.files[names[i]].storeAt.Before(c.files[names[j]].storeAt)
+	})
+
+	target := c.maxBytes / 10 * 9
+	for _, name := range names {
+		if c.size <= target {
+			return
+		}
+
+		if err := os.Remove(filepath.Join(c.dir, name)); err != nil && !os.IsNotExist(err) {
+			continue
+		}
+
+		c.size -= c.files[name].size
+		delete(c.files, name)
+	}
+}
+
+func cacheFileName(source string) string {
+	sum := sha256.Sum256([]byte(source))
+	return hex.EncodeToString(sum[:]) + diskCacheExt
+}
@@ -0,0 +1,112 @@
+package kpu
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiskCacheTTL(t *testing.T) {
+	cache, err := OpenDiskCache(DiskCacheConfig{
+		Dir:         t.TempDir(),
+		LocationTTL: time.Hour,
+		VoteTTL:     time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	location := "https://sirekap/wilayah/pemilu/ppwp/0.json"
+	votes := "https://sirekap/pemilu/hhcw/ppwp.json"
+	cache.put(location, []byte(`[]`))
+	cache.put(votes, []byte(`{}`))
+
+	if body, ok := cache.get("wilayah/pemilu/ppwp", location); !ok || string(body) != `[]` {
+		t.Errorf("fresh location = %q, %t", body, ok)
+	}
+	if _, ok := cache.get("pemilu/hhcw", votes); !ok {
+		t.Error("fresh votes not served")
+	}
+
+	// ten minutes later the votes are stale, the locations are not
+	for name, file := range cache.files {
+		file.storeAt = file.storeAt.Add(-10 * time.Minute)
+		cache.files[name] = file
+	}
+
+	if _, ok := cache.get("wilayah/pemilu/ppwp", location); !ok {
+		t.Error("location stale after 10 minutes, want fresh for an hour")
+	}
+	if _, ok := cache.get("pemilu/hhcw", votes); ok {
+		t.Error("votes fresh after 10 minutes, want stale after a minute")
+	}
+
+	// a TTL of zero disables the cache of the endpoint
+	cache.voteTTL = 0
+	cache.put(votes, []byte(`{}`))
+	if _, ok := cache.get("pemilu/hhcw", votes); ok {
+		t.Error("votes served with a zero TTL")
+	}
+}
+
+func TestDiskCacheEviction(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := OpenDiskCache(DiskCacheConfig{Dir: dir, LocationTTL: 24 * time.Hour, MaxBytes: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sources := []string{"https://sirekap/wilayah/a.json", "https://sirekap/wilayah/b.json", "https://sirekap/wilayah/c.json"}
+	body := bytes.Repeat([]byte("x"), 40)
+	for i, source := range sources {
+		cache.put(source, body)
+
+		// the first ones are the oldest
+		name := cacheFileName(source)
+		file := cache.files[name]
+		if file.size > 0 {
+			file.storeAt = time.Now().Add(time.Duration(i-len(sources)) * time.Hour)
+			cache.files[name] = file
+		}
+	}
+
+	// 120 bytes over the cap of 100: the oldest goes until the cache is under 90
+	if entries, size := cache.Size(); entries != 2 || size != 80 {
+		t.Errorf("Size() = %d, %d, want 2 entries of 80 bytes", entries, size)
+	}
+	if _, err := os.Stat(filepath.Join(dir, cacheFileName(sources[0]))); !os.IsNotExist(err) {
+		t.Errorf("oldest entry still on disk: %v", err)
+	}
+	for _, source := range sources[1:] {
+		if _, ok := cache.get("wilayah/pemilu/ppwp", source); !ok {
+			t.Errorf("%s evicted, want only the oldest evicted", source)
+		}
+	}
+
+	// the next run indexes what is on disk, the temporary files of an interrupted put aside
+	err = os.WriteFile(filepath.Join(dir, cacheFileName(sources[0])+".tmp123"), body, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenDiskCache(DiskCacheConfig{Dir: dir, LocationTTL: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries, size := reopened.Size(); entries != 2 || size != 80 {
+		t.Errorf("reopened Size() = %d, %d, want 2 entries of 80 bytes", entries, size)
+	}
+
+	if err := reopened.Purge(); err != nil {
+		t.Fatal(err)
+	}
+	names, _ := os.ReadDir(dir)
+	for _, name := range names {
+		if strings.HasSuffix(name.Name(), diskCacheExt) {
+			t.Errorf("%s left after Purge", name.Name())
+		}
+	}
+}
@@ -0,0 +1,432 @@
+package kpu
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DriftKind is the kind of difference between a Sirekap response and the struct it is decoded into.
+type DriftKind string
+
+const (
+	// DriftUnknownField is a field Sirekap sends that the struct does not have, it is dropped on decode.
+	DriftUnknownField DriftKind = "unknown_field"
+	// DriftMissingField is a field of the struct Sirekap did not send, it is left zero on decode.
+	DriftMissingField DriftKind = "missing_field"
+	// DriftTypeMismatch is a value of another JSON type than the field, e.g. null for an int64.
+	DriftTypeMismatch DriftKind = "type_mismatch"
+	// DriftUnknownValue is a value outside of the known ones, e.g. a psu other than Reguler.
+	DriftUnknownValue DriftKind = "unknown_value"
+	// DriftUnknownKey is a chart key that is neither a candidate nor a party of the registry, it is
+	// counted as votes of an unknown candidate or party.
+	DriftUnknownKey DriftKind = "unknown_key"
+)
+
+// Drift is one difference seen on an endpoint, with the number of responses it was seen in.
+type Drift struct {
+	// Endpoint is the URL path with the location codes replaced, e.g. pemilu/hhcw/ppwp/{kode}/{kode}.
+	Endpoint string    `json:"endpoint"`
+	Kind     DriftKind `json:"kind"`
+	// Path is the position in the response, * for any map key and [] for any array item, e.g. table.*.psu.
+	Path     string `json:"path"`
+	Expected string `json:"expected,omitempty"`
+	Got      string `json:"got,omitempty"`
+	Count    int64  `json:"count"`
+	// URL is the first response the drift was seen in.
+	URL       string    `json:"url"`
+	FirstSeen time.Time `json:"first_seen"`
+}
+
+// DriftReport collects the drifts of every response decoded by a Sirekap in strict mode.
+// Decoding itself is unchanged, a drift never fails a request.
+type DriftReport struct {
+	mu        sync.Mutex
+	responses int64
+	drifts    map[string]*Drift
+	// keys are the valid chart keys per election, an election without keys accepts any
+	keys map[Election]map[string]bool
+}
+
+// NewDriftReport checks the chart keys against DefaultCandidatesPresidential and DefaultParties.
+// The DPD candidates differ per province and are not checked.
+func NewDriftReport() *DriftReport {
+	candidates := make(map[string]bool, len(DefaultCandidatesPresidential))
+	for id := range DefaultCandidatesPresidential {
+		candidates[id] = true
+	}
+
+	parties := make(map[string]bool, len(DefaultParties))
+	for id := range DefaultParties {
+		parties[id] = true
+	}
+
+	return &DriftReport{
+		drifts: make(map[string]*Drift),
+		keys: map[Election]map[string]bool{
+			Presidential: candidates,
+			DPR:          parties,
+			DPRDProvince: parties,
+			DPRDRegency:  parties,
+		},
+	}
+}
+
+// WithDriftReport enables the strict mode: every response fetched from Sirekap is compared
+// with the struct it is decoded into and the differences are recorded in report, including
+// the responses served from the disk cache.
+func WithDriftReport(report *DriftReport) Option {
+	return func(s *Sirekap) {
+		s.drift = report
+	}
+}
+
+// Responses is the number of responses compared so far.
+func (r *DriftReport) Responses() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.responses
+}
+
+// Drifts returns the drifts seen so far, ordered by endpoint, path and kind.
+func (r *DriftReport) Drifts() []Drift {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	drifts := make([]Drift, 0, len(r.drifts))
+	for _, drift := range r.drifts {
+		drifts = append(drifts, *drift)
+	}
+
+	sort.Slice(drifts, func(i, j int) bool {
+		if drifts[i].Endpoint != drifts[j].Endpoint {
+			return drifts[i].Endpoint < drifts[j].Endpoint
+		}
+		if drifts[i].Path != drifts[j].Path {
+			return drifts[i].Path < drifts[j].Path
+		}
+		if drifts[i].Kind != drifts[j].Kind {
+			return drifts[i].Kind < drifts[j].Kind
+		}
+		return drifts[i].Got < drifts[j].Got
+	})
+
+	return drifts
+}
+
+// Save writes the report as JSON to name, replacing the previous one.
+func (r *DriftReport) Save(name string) error {
+	data, err := json.MarshalIndent(struct {
+		Responses int64   `json:"responses"`
+		Drifts    []Drift `json:"drifts"`
+	}{r.Responses(), r.Drifts()}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error on encode drift report: %w", err)
+	}
+
+	return writeFileAtomic(name, data)
+}
+
+// check compares body with the type of dest. A nil report checks nothing.
+func (r *DriftReport) check(source, basePath string, dynamicPaths []string, body []byte, dest any) {
+	if r == nil {
+		return
+	}
+
+	var raw any
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if dec.Decode(&raw) != nil {
+		// a body that is not JSON at all failed the request, there is no shape to compare
+		return
+	}
+
+	endpoint := driftEndpoint(basePath, dynamicPaths)
+	now := time.Now()
+
+	var keys map[string]bool
+	if basePath == "pemilu/hhcw" && len(dynamicPaths) > 0 {
+		keys = r.keys[Election(dynamicPaths[0])]
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.responses++
+	seen := make(map[string]bool)
+	walk := driftWalk{keys: keys}
+	walk.report = func(kind DriftKind, path, expected, got string) {
+		key := strings.Join([]string{endpoint, string(kind), path, got}, "\x00")
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+
+		if drift, ok := r.drifts[key]; ok {
+			drift.Count++
+			return
+		}
+
+		r.drifts[key] = &Drift{
+			Endpoint:  endpoint,
+			Kind:      kind,
+			Path:      path,
+			Expected:  expected,
+			Got:       got,
+			Count:     1,
+			URL:       source,
+			FirstSeen: now,
+		}
+		log.Printf("kpu: schema drift on %s: %s %s (expected %s, got %s)\n", endpoint, kind, path, expected, got)
+	}
+
+	walk.compare(reflect.TypeOf(dest).Elem(), raw, "")
+}
+
+// driftEndpoint groups the URLs of one endpoint, every numeric path segment being a location or dapil code.
+func driftEndpoint(basePath string, dynamicPaths []string) string {
+	segments := []string{basePath}
+	for _, segment := range dynamicPaths {
+		if strings.Trim(segment, "0123456789") == "" {
+			segment = "{kode}"
+		}
+		segments = append(segments, segment)
+	}
+
+	return strings.Join(segments, "/")
+}
+
+var (
+	timeType          = reflect.TypeOf(Time{})
+	tableRowType      = reflect.TypeOf(TableRow{})
+	partyVotesTPSType = reflect.TypeOf(PartyVotesTPS{})
+	psuType           = reflect.TypeOf(PSU(""))
+)
+
+// driftWalk compares a response with a type.
+type driftWalk struct {
+	// keys are the valid chart keys, nil accepts any
+	keys   map[string]bool
+	report func(kind DriftKind, path, expected, got string)
+}
+
+// checkKey reports a chart key missing from the registry. The status fields Sirekap mixes
+// into some charts, e.g. persen, are not votes and always valid.
+func (w driftWalk) checkKey(key, path string) {
+	if w.keys == nil || w.keys[key] {
+		return
+	}
+
+	switch key {
+	case "psu", "persen", "status_progress":
+		return
+	}
+
+	w.report(DriftUnknownKey, joinPath(path, "*"), "candidate or party ID", key)
+}
+
+// compare walks raw, decoded with UseNumber, along t and reports every difference.
+func (w driftWalk) compare(t reflect.Type, raw any, path string) {
+	report := w.report
+
+	if t.Kind() == reflect.Pointer {
+		if raw == nil {
+			return
+		}
+		t = t.Elem()
+	}
+
+	if t.Kind() == reflect.Interface {
+		return
+	}
+
+	expected := jsonType(t)
+	if raw == nil {
+		report(DriftTypeMismatch, path, expected, "null")
+		return
+	}
+
+	if got := rawType(raw); got != expected && !(expected == "integer" && got == "number") {
+		report(DriftTypeMismatch, path, expected, got)
+		return
+	}
+
+	switch {
+	case t == timeType:
+		return
+	case t == tableRowType:
+		for key, value := range raw.(map[string]any) {
+			switch key {
+			case "psu":
+				w.compare(reflect.TypeOf((*PSU)(nil)), value, joinPath(path, key))
+			case "persen":
+				w.compare(reflect.TypeOf((*float64)(nil)), value, joinPath(path, key))
+			case "status_progress":
+				w.compare(reflect.TypeOf((*bool)(nil)), value, joinPath(path, key))
+			default:
+				// a null vote is a region not counted yet
+				w.checkKey(key, path)
+				w.compare(reflect.TypeOf((*int64)(nil)), value, joinPath(path, "*"))
+			}
+		}
+		return
+	case t == partyVotesTPSType:
+		// the caleg IDs are not in the registry, only the types are checked
+		for _, value := range raw.(map[string]any) {
+			w.compare(reflect.TypeOf((*int64)(nil)), value, joinPath(path, "*"))
+		}
+		return
+	case t == psuType:
+		if value := PSU(raw.(string)); !value.Known() {
+			report(DriftUnknownValue, path, fmt.Sprint(KnownPSU), string(value))
+		}
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		object := raw.(map[string]any)
+		fields := jsonFields(t)
+		for key, value := range object {
+			field, ok := fields[key]
+			if !ok {
+				report(DriftUnknownField, joinPath(path, key), "", rawType(value))
+				continue
+			}
+			w.compare(field.typ, value, joinPath(path, key))
+		}
+
+		for name, field := range fields {
+			if _, ok := object[name]; !ok && !field.optional {
+				report(DriftMissingField, joinPath(path, name), jsonType(field.typ), "")
+			}
+		}
+	case reflect.Map:
+		// a map of votes or of party results is a chart, keyed by candidate or party ID,
+		// while the tables are keyed by region code
+		chart := isChartValue(t.Elem())
+		for key, value := range raw.(map[string]any) {
+			if chart {
+				w.checkKey(key, path)
+			}
+			w.compare(t.Elem(), value, joinPath(path, "*"))
+		}
+	case reflect.Slice, reflect.Array:
+		for _, value := range raw.([]any) {
+			w.compare(t.Elem(), value, path+"[]")
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		number := raw.(json.Number)
+		if _, err := number.Int64(); err != nil {
+			report(DriftTypeMismatch, path, expected, "number")
+		}
+	}
+}
+
+// isChartValue tells if t is the value type of a chart: votes, or the result of a party.
+func isChartValue(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return t == partyVotesTPSType
+	}
+}
+
+type jsonField struct {
+	typ      reflect.Type
+	optional bool
+}
+
+// jsonFields returns the fields of t by JSON name, the way encoding/json names them.
+func jsonFields(t reflect.Type) map[string]jsonField {
+	fields := make(map[string]jsonField, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+
+		fields[name] = jsonField{typ: field.Type, optional: strings.Contains(options, "omitempty")}
+	}
+
+	return fields
+}
+
+// jsonType is the JSON type t is decoded from.
+func jsonType(t reflect.Type) string {
+	if t.Kind() == reflect.Pointer {
+		return jsonType(t.Elem()) + " or null"
+	}
+
+	switch {
+	case t == timeType:
+		return "string"
+	case t == tableRowType, t == partyVotesTPSType:
+		return "object"
+	}
+
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map:
+		return "object"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	default:
+		return t.String()
+	}
+}
+
+// rawType is the JSON type of a value decoded with UseNumber.
+func rawType(raw any) string {
+	switch raw.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	default:
+		return fmt.Sprintf("%T", raw)
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
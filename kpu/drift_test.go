@@ -0,0 +1,163 @@
+package kpu_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pararang/pemilu2024/kpu"
+	"github.com/pararang/pemilu2024/kpu/kputest"
+)
+
+// driftServer serves the synthetic tree with the nationwide presidential response rewritten by edit.
+func driftServer(t *testing.T, edit func(response map[string]any)) *kputest.Server {
+	t.Helper()
+
+	fsys := kputest.Synthetic(kputest.DefaultTree)
+	file := fsys["pemilu/hhcw/ppwp.json"]
+
+	var response map[string]any
+	err := json.Unmarshal(file.Data, &response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edit(response)
+
+	file.Data, err = json.Marshal(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := kputest.NewServer(fsys)
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func findDrift(drifts []kpu.Drift, kind kpu.DriftKind, path string) (kpu.Drift, bool) {
+	for _, drift := range drifts {
+		if drift.Kind == kind && drift.Path == path {
+			return drift, true
+		}
+	}
+
+	return kpu.Drift{}, false
+}
+
+func TestDriftReportSynthetic(t *testing.T) {
+	srv := kputest.NewSyntheticServer(kputest.DefaultTree)
+	defer srv.Close()
+
+	report := kpu.NewDriftReport()
+	sirekap := srv.Sirekap(kpu.WithDriftReport(report))
+	ctx := context.Background()
+
+	_, err := sirekap.GetVotesPresidentialNationwide(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = sirekap.GetVotesLegislativeByRegion(ctx, "11")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Responses() != 2 {
+		t.Errorf("%d responses checked, want 2", report.Responses())
+	}
+	if drifts := report.Drifts(); len(drifts) != 0 {
+		t.Errorf("drifts on the synthetic responses: %+v", drifts)
+	}
+}
+
+func TestDriftReport(t *testing.T) {
+	srv := driftServer(t, func(response map[string]any) {
+		delete(response, "mode")
+		response["versi"] = 2
+		response["psu"] = "Susulan"
+		response["chart"].(map[string]any)["100099"] = 12
+		response["chart"].(map[string]any)["100026"] = nil
+	})
+
+	report := kpu.NewDriftReport()
+	sirekap := srv.Sirekap(kpu.WithDriftReport(report))
+
+	// drifts never fail a request
+	for i := 0; i < 2; i++ {
+		_, err := sirekap.GetVotesPresidentialNationwide(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	drifts := report.Drifts()
+	for _, want := range []struct {
+		kind kpu.DriftKind
+		path string
+		got  string
+	}{
+		{kind: kpu.DriftMissingField, path: "mode"},
+		{kind: kpu.DriftUnknownField, path: "versi", got: "number"},
+		{kind: kpu.DriftUnknownValue, path: "psu", got: "Susulan"},
+		{kind: kpu.DriftUnknownKey, path: "chart.*", got: "100099"},
+		{kind: kpu.DriftTypeMismatch, path: "chart.*", got: "null"},
+	} {
+		drift, ok := findDrift(drifts, want.kind, want.path)
+		if !ok {
+			t.Errorf("no %s drift at %s in %+v", want.kind, want.path, drifts)
+			continue
+		}
+		if drift.Got != want.got || drift.Endpoint != "pemilu/hhcw/ppwp" || drift.Count != 2 {
+			t.Errorf("drift %+v, want got %q on pemilu/hhcw/ppwp seen twice", drift, want.got)
+		}
+	}
+
+	name := filepath.Join(t.TempDir(), "drift.json")
+	err := report.Save(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var saved struct {
+		Responses int64       `json:"responses"`
+		Drifts    []kpu.Drift `json:"drifts"`
+	}
+	err = json.Unmarshal(data, &saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Responses != 2 || len(saved.Drifts) != len(drifts) {
+		t.Errorf("saved %d responses and %d drifts, want 2 and %d", saved.Responses, len(saved.Drifts), len(drifts))
+	}
+}
+
+func TestDriftReportMalformedBody(t *testing.T) {
+	srv := driftServer(t, func(response map[string]any) {
+		response["table"].(map[string]any)["11"].(map[string]any)["100025"] = "1234"
+	})
+
+	report := kpu.NewDriftReport()
+	sirekap := srv.Sirekap(kpu.WithDriftReport(report))
+
+	_, err := sirekap.GetVotesPresidentialNationwide(context.Background())
+	if !errors.Is(err, kpu.ErrMalformedBody) {
+		t.Fatalf("error %v, want kpu.ErrMalformedBody", err)
+	}
+
+	// the drift that broke the decoding is what the strict mode is for
+	drift, ok := findDrift(report.Drifts(), kpu.DriftTypeMismatch, "table.*.*")
+	if !ok || drift.Got != "string" {
+		t.Errorf("drifts %+v, want the string vote as a type mismatch", report.Drifts())
+	}
+	if report.Responses() != 1 {
+		t.Errorf("%d responses checked, want 1", report.Responses())
+	}
+}
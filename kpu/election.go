@@ -0,0 +1,94 @@
+package kpu
+
+import (
+	"context"
+	"fmt"
+)
+
+// Election is one of the five ballots of the 2024 election, named like its Sirekap path segment.
+type Election string
+
+const (
+	Presidential Election = "ppwp"
+	DPR          Election = "pdpr"
+	DPD          Election = "pdpd"
+	// DPRDProvince and DPRDRegency are the provincial and regency/city legislatures,
+	// where local parties (e.g. the Aceh parties) run next to the national ones.
+	DPRDProvince Election = "pdprdp"
+	DPRDRegency  Election = "pdprdk"
+)
+
+var Elections = []Election{Presidential, DPR, DPD, DPRDProvince, DPRDRegency}
+
+// TopLevel is the highest location level having an aggregate for the election:
+// 0 is nationwide, 1 province, 2 regency/city. A DPRD is only elected within its province
+// or regency, so there is nothing to sum above it.
+func (e Election) TopLevel() int {
+	switch e {
+	case DPD, DPRDProvince:
+		return 1
+	case DPRDRegency:
+		return 2
+	default:
+		return 0
+	}
+}
+
+func (e Election) Valid() bool {
+	for _, election := range Elections {
+		if e == election {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ResponseDataRegion is the aggregate of any election for a region. Chart and every row of Table
+// are keyed by candidate or party ID, depending on the election.
+type ResponseDataRegion struct {
+	Ts      Time                `json:"ts"`
+	PSU     PSU                 `json:"psu"`
+	Mode    string              `json:"mode"`
+	Chart   TableRow            `json:"chart"`
+	Table   map[string]TableRow `json:"table"`
+	Progres Progres             `json:"progres"`
+}
+
+// GetVotesRegion returns the aggregate of election for the region identified by code,
+// e.g. https://sirekap-obj-data.kpu.go.id/pemilu/hhcw/pdprdk/73/7371.json for DPRDRegency and 7371.
+// Nationwide is only valid for elections with TopLevel 0.
+//
+// The DPRD results are published per administrative region only. The DPRD dapil, which group the
+// regencies of a province or the districts of a regency, are not modelled: sum the regions of a dapil.
+func (s *Sirekap) GetVotesRegion(ctx context.Context, election Election, code Code) (ResponseDataRegion, error) {
+	if !election.Valid() {
+		return ResponseDataRegion{}, fmt.Errorf("unknown election %q", election)
+	}
+
+	paths, err := code.segments(election.TopLevel(), LevelVillage)
+	if err != nil {
+		return ResponseDataRegion{}, fmt.Errorf("%s: %w", election, err)
+	}
+
+	var votes ResponseDataRegion
+	err = s.fetchVotes(ctx, &votes, append([]string{string(election)}, paths...)...)
+	if err != nil {
+		return ResponseDataRegion{}, fmt.Errorf("error on fetchVotes: %w", err)
+	}
+
+	return votes, nil
+}
+
+// IDs returns the candidate or party IDs of the chart by numeric ID, which is not the ballot order:
+// use PartyRegistry.Ballot or Columns to order the parties for output.
+func (r ResponseDataRegion) IDs() []string {
+	ids := make([]string, 0, len(r.Chart.Votes))
+	for id := range r.Chart.Votes {
+		ids = append(ids, id)
+	}
+
+	sortNumeric(ids)
+
+	return ids
+}
@@ -0,0 +1,76 @@
+package kpu
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var (
+	// ErrInvalidCode is returned before any request is made when a location code is malformed.
+	ErrInvalidCode = errors.New("sirekap: invalid location code")
+	// ErrNotFound is returned when Sirekap has no object for the requested path,
+	// which for a TPS usually means its data has not been published yet.
+	ErrNotFound = errors.New("sirekap: not found")
+	// ErrRateLimited is returned when Sirekap or its WAF rejects the request (403/429).
+	ErrRateLimited = errors.New("sirekap: rate limited")
+	// ErrUnavailable is returned for 5xx responses and any other unexpected status.
+	ErrUnavailable = errors.New("sirekap: upstream unavailable")
+	// ErrMalformedBody is returned when a 2xx response can not be decoded.
+	ErrMalformedBody = errors.New("sirekap: malformed body")
+)
+
+const maxBodyExcerpt = 256
+
+// UpstreamError describes a failed Sirekap response. It matches one of the sentinel errors
+// above through errors.Is.
+type UpstreamError struct {
+	Kind       error
+	URL        string
+	StatusCode int
+	Body       string
+	// RetryAfter is the delay requested by the server through the Retry-After header, if any.
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *UpstreamError) Error() string {
+	msg := fmt.Sprintf("%s: %s [%d]", e.Kind, e.URL, e.StatusCode)
+	if e.Err != nil {
+		msg = fmt.Sprintf("%s: %s", msg, e.Err)
+	}
+
+	if e.Body != "" {
+		msg = fmt.Sprintf("%s: %q", msg, e.Body)
+	}
+
+	return msg
+}
+
+func (e *UpstreamError) Is(target error) bool {
+	return e.Kind == target
+}
+
+func (e *UpstreamError) Unwrap() error {
+	return e.Err
+}
+
+func errorKind(statusCode int) error {
+	switch {
+	case statusCode == http.StatusNotFound:
+		return ErrNotFound
+	case statusCode == http.StatusForbidden, statusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	default:
+		return ErrUnavailable
+	}
+}
+
+func bodyExcerpt(body []byte) string {
+	if len(body) > maxBodyExcerpt {
+		return string(body[:maxBodyExcerpt]) + "..."
+	}
+
+	return string(body)
+}
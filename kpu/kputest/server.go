@@ -0,0 +1,93 @@
+// Package kputest provides an offline Sirekap server for tests and local development.
+//
+// The server serves the same paths as the KPU object storage, e.g.
+// wilayah/pemilu/ppwp/{codes}.json and pemilu/hhcw/{ppwp,pdpr}/{codes}.json,
+// either from a fixture directory or from a generated synthetic tree.
+package kputest
+
+import (
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+
+	"github.com/pararang/pemilu2024/kpu"
+)
+
+type Server struct {
+	*httptest.Server
+}
+
+// NewServer starts a server answering Sirekap paths from fsys.
+// A path like /pemilu/hhcw/ppwp.json is read from "pemilu/hhcw/ppwp.json" in fsys.
+func NewServer(fsys fs.FS) *Server {
+	return &Server{
+		Server: httptest.NewServer(handler(fsys)),
+	}
+}
+
+// NewFixtureServer starts a server answering Sirekap paths from files under dir.
+func NewFixtureServer(dir string) *Server {
+	return NewServer(os.DirFS(dir))
+}
+
+// Sirekap returns a client pointed at this server, without the default rate limit.
+// Absolute URLs of other hosts, like the C1 scans, are served by this server as well.
+func (s *Server) Sirekap(opts ...kpu.Option) *kpu.Sirekap {
+	defaults := []kpu.Option{
+		kpu.WithHost(s.URL),
+		kpu.WithRateLimit(kpu.RateLimit{}),
+	}
+
+	client := s.Client()
+	client.Transport = &rewriteTransport{target: s.Listener.Addr().String(), transport: client.Transport}
+
+	return kpu.NewSirekap(client, append(defaults, opts...)...)
+}
+
+// rewriteTransport sends every request to target, whatever its host.
+type rewriteTransport struct {
+	target    string
+	transport http.RoundTripper
+}
+
+func (t *rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = "http"
+	req.URL.Host = t.target
+
+	return t.transport.RoundTrip(req)
+}
+
+func handler(fsys fs.FS) http.Handler {
+	files := http.FileServer(http.FS(fsys))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Sirekap only serves json objects, never directory listings
+		info, err := fs.Stat(fsys, trimSlash(r.URL.Path))
+		if err != nil || info.IsDir() {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		files.ServeHTTP(w, r)
+	})
+}
+
+func trimSlash(path string) string {
+	for len(path) > 0 && path[0] == '/' {
+		path = path[1:]
+	}
+
+	if path == "" {
+		return "."
+	}
+
+	return path
+}
@@ -0,0 +1,517 @@
+package kputest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"path"
+	"strconv"
+	"strings"
+	"testing/fstest"
+
+	"github.com/pararang/pemilu2024/kpu"
+)
+
+const syntheticTs = "2024-02-25 16:00:16"
+
+var (
+	syntheticCandidates = []string{"100025", "100026", "100027"}
+	syntheticParties    = []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12", "13", "14", "15", "16", "17", "24"}
+)
+
+// Tree describes the shape of a generated Sirekap tree, as the number of children per level.
+type Tree struct {
+	Provinces int
+	Cities    int
+	Districts int
+	Villages  int
+	TPS       int
+	// Countries is the number of countries of the Luar Negeri branch, none when zero. Each has one PPLN
+	// with a TPSLN, a KSK and a POS group, of TPS polling places each.
+	Countries int
+	Seed      int64
+}
+
+// DefaultTree is small enough to crawl in a unit test, yet has every level populated.
+var DefaultTree = Tree{
+	Provinces: 2,
+	Cities:    2,
+	Districts: 2,
+	Villages:  2,
+	TPS:       2,
+	Countries: 1,
+	Seed:      2024,
+}
+
+// NewSyntheticServer starts a server answering Sirekap paths from a tree generated by Synthetic.
+func NewSyntheticServer(tree Tree) *Server {
+	return NewServer(Synthetic(tree))
+}
+
+// Synthetic generates a deterministic Sirekap tree: location lists for every level, the votes
+// of every election per TPS and region, the candidate master data and fake C1 scans under synthetic/.
+// The result can be served as is, or written to disk to be used as fixtures.
+func Synthetic(tree Tree) fstest.MapFS {
+	g := &generator{
+		tree:  tree,
+		rand:  rand.New(rand.NewSource(tree.Seed)),
+		files: make(fstest.MapFS),
+	}
+
+	g.generate()
+
+	return g.files
+}
+
+type generator struct {
+	tree  Tree
+	rand  *rand.Rand
+	files fstest.MapFS
+	id    int64
+}
+
+// levels describes how the codes of each level are built from the parent code, index 0 is the province.
+var levels = []struct {
+	format string
+	first  int
+}{
+	{"%02d", 11},
+	{"%02d", 1},
+	{"%02d", 1},
+	{"%04d", 1001},
+	{"%03d", 1},
+}
+
+// tally is the sum of the votes below a region, per election.
+type tally struct {
+	votes map[kpu.Election]map[string]int64
+	// caleg is the DPR chart with the caleg votes, keyed by party ID
+	caleg map[string]map[string]int64
+	tps   int64
+}
+
+func newTally() tally {
+	t := tally{
+		votes: make(map[kpu.Election]map[string]int64, len(kpu.Elections)),
+		caleg: make(map[string]map[string]int64, len(syntheticParties)),
+	}
+	for _, election := range kpu.Elections {
+		t.votes[election] = make(map[string]int64)
+	}
+
+	return t
+}
+
+func (g *generator) generate() {
+	g.writeMasterData()
+	g.region(kpu.Location{Code: kpu.Nationwide})
+}
+
+// writeMasterData writes the candidate pairs and the parties the charts are keyed by.
+func (g *generator) writeMasterData() {
+	candidates := make(map[string]any, len(syntheticCandidates))
+	for i, id := range syntheticCandidates {
+		candidates[id] = map[string]any{
+			"ts":         syntheticTs,
+			"nama":       fmt.Sprintf("CALON PRESIDEN %d - CALON WAKIL PRESIDEN %d", i+1, i+1),
+			"nomor_urut": i + 1,
+			"warna":      "#000000",
+		}
+	}
+
+	g.writeJSON("pemilu/ppwp.json", candidates)
+	g.writeJSON("pemilu/partai.json", kpu.DefaultParties)
+}
+
+// region writes the files of loc and everything below it.
+func (g *generator) region(loc kpu.Location) tally {
+	if loc.Level == kpu.LevelTPS {
+		return g.tps(loc)
+	}
+
+	var (
+		fanOut = []int{g.tree.Provinces, g.tree.Cities, g.tree.Districts, g.tree.Villages, g.tree.TPS}
+		level  = levels[loc.Level]
+		total  = newTally()
+		tables = make(map[kpu.Election]map[string]any, len(kpu.Elections))
+	)
+
+	var children kpu.Locations
+	if loc.Code.IsOverseas() {
+		children = g.overseasChildren(loc)
+	} else {
+		children = g.children(loc.Code, loc.Level+1, fanOut[loc.Level], level.format, level.first)
+	}
+
+	if loc.Code == kpu.Nationwide && g.tree.Countries > 0 {
+		g.id++
+		children = append(children, kpu.Location{Name: "LUAR NEGERI", ID: g.id, Code: kpu.Overseas, Level: kpu.LevelProvince})
+	}
+
+	if loc.Code == kpu.Nationwide {
+		g.writeLocations("wilayah/pemilu/ppwp/0.json", children)
+	} else {
+		g.writeLocations(codePath("wilayah/pemilu/ppwp", loc.Code), children)
+	}
+
+	for _, election := range kpu.Elections {
+		tables[election] = make(map[string]any, len(children))
+	}
+
+	calegTable := make(map[string]any, len(children))
+	for _, child := range children {
+		sub := g.region(child)
+		total.tps += sub.tps
+		for election, votes := range sub.votes {
+			addVotes(total.votes[election], votes)
+			tables[election][string(child.Code)] = tableRow(votes)
+		}
+
+		for party, votes := range sub.caleg {
+			if total.caleg[party] == nil {
+				total.caleg[party] = make(map[string]int64, len(votes))
+			}
+			addVotes(total.caleg[party], votes)
+		}
+		calegTable[string(child.Code)] = sub.caleg
+	}
+
+	progres := map[string]int64{"total": total.tps, "progres": total.tps}
+
+	for _, election := range kpu.Elections {
+		// e.g. a DPRD is elected per province or regency, there is nothing above it
+		if loc.Level < int64(election.TopLevel()) {
+			continue
+		}
+
+		if loc.Code.IsOverseas() && !overseasElection(election) {
+			continue
+		}
+
+		// like Sirekap, the chart of a region has the progress percentage next to the votes
+		chart := make(map[string]any, len(total.votes[election])+1)
+		for key, votes := range total.votes[election] {
+			chart[key] = votes
+		}
+		chart["persen"] = 78.5
+
+		g.writeJSON(codePath(path.Join("pemilu/hhcw", string(election)), loc.Code), map[string]any{
+			"ts":      syntheticTs,
+			"psu":     kpu.Reguler,
+			"mode":    "hhcw",
+			"chart":   chart,
+			"table":   tables[election],
+			"progres": progres,
+		})
+	}
+
+	switch {
+	case loc.Level == kpu.LevelNationwide:
+		g.writeDapils(children)
+	case loc.Level == kpu.LevelProvince && !loc.Code.IsOverseas():
+		g.writeCandidatesDPD(string(loc.Code))
+
+		// a synthetic province is a single dapil
+		dapil := syntheticDapil(string(loc.Code))
+		g.writeCandidatesDPR(dapil)
+		g.writeJSON(path.Join("pemilu/hhcw/pdpr/dapil", dapil+".json"), map[string]any{
+			"ts":      syntheticTs,
+			"psu":     kpu.Reguler,
+			"mode":    "hhcw",
+			"chart":   total.caleg,
+			"table":   calegTable,
+			"progres": progres,
+		})
+	}
+
+	return total
+}
+
+func (g *generator) tps(loc kpu.Location) tally {
+	chart := g.votes(syntheticCandidates, 150)
+
+	// the scans are fake, but distinct per TPS so their checksums differ
+	for page := 1; page <= 2; page++ {
+		g.files[fmt.Sprintf("synthetic/%s-%d.jpg", loc.Code, page)] = &fstest.MapFile{Data: []byte(fmt.Sprintf("C1 %s page %d", loc.Code, page))}
+	}
+
+	g.writeJSON(codePath("pemilu/hhcw/ppwp", loc.Code), map[string]any{
+		"chart": chart,
+		"images": []string{
+			fmt.Sprintf("https://sirekap-obj-formc.kpu.go.id/synthetic/%s-1.jpg", loc.Code),
+			fmt.Sprintf("https://sirekap-obj-formc.kpu.go.id/synthetic/%s-2.jpg", loc.Code),
+		},
+		"administrasi": g.administrasi(chart),
+		"psu":          nil,
+		"ts":           syntheticTs,
+		"status_suara": true,
+		"status_adm":   false,
+	})
+
+	partyTotals := make(map[string]int64, len(syntheticParties))
+	partyChart := make(map[string]map[string]int64, len(syntheticParties))
+	for _, party := range syntheticParties {
+		votes := g.votes(syntheticCandidatesOf(party), 10)
+
+		var total int64
+		for _, v := range votes {
+			total += v
+		}
+
+		partyVotes := int64(g.rand.Intn(11))
+		votes["jml_suara_partai"] = partyVotes
+		votes["jml_suara_total"] = total + partyVotes
+
+		partyTotals[party] = total + partyVotes
+		partyChart[party] = votes
+	}
+
+	g.writeJSON(codePath("pemilu/hhcw/pdpr", loc.Code), map[string]any{
+		"chart":        partyChart,
+		"images":       []string{fmt.Sprintf("https://sirekap-obj-formc.kpu.go.id/synthetic/%s-dpr-1.jpg", loc.Code)},
+		"administrasi": nil,
+		"psu":          nil,
+		"ts":           syntheticTs,
+		"status_suara": true,
+		"status_adm":   false,
+	})
+
+	votes := map[kpu.Election]map[string]int64{
+		kpu.Presidential: chart,
+		kpu.DPR:          partyTotals,
+	}
+
+	if !loc.Code.IsOverseas() {
+		provinceCode := string(loc.Code.Province())
+		votes[kpu.DPD] = g.votes(syntheticCandidatesDPD(provinceCode), 80)
+		votes[kpu.DPRDProvince] = g.votes(syntheticLocalParties(provinceCode), 30)
+		votes[kpu.DPRDRegency] = g.votes(syntheticLocalParties(provinceCode), 30)
+	}
+
+	return tally{
+		caleg: partyChart,
+		votes: votes,
+		tps:   1,
+	}
+}
+
+// overseasChildren generates the children of a location of the Luar Negeri branch: the countries,
+// the PPLN, the voting method groups and the polling places named after their method.
+func (g *generator) overseasChildren(loc kpu.Location) kpu.Locations {
+	switch loc.Level {
+	case kpu.LevelProvince:
+		children := g.children(loc.Code, kpu.LevelRegency, g.tree.Countries, "%02d", 1)
+		for i := range children {
+			children[i].Name = fmt.Sprintf("SYNTHETIC NEGARA %s", children[i].Code)
+		}
+		return children
+	case kpu.LevelRegency:
+		children := g.children(loc.Code, kpu.LevelDistrict, 1, "%02d", 1)
+		children[0].Name = fmt.Sprintf("SYNTHETIC PPLN %s", children[0].Code)
+		return children
+	case kpu.LevelDistrict:
+		children := g.children(loc.Code, kpu.LevelVillage, 3, "%04d", 1001)
+		for i, name := range []string{"TPSLN", "KSK", "POS"} {
+			children[i].Name = name
+		}
+		return children
+	default:
+		children := g.children(loc.Code, kpu.LevelTPS, g.tree.TPS, "%03d", 1)
+		for i := range children {
+			children[i].Name = fmt.Sprintf("%s %03d", loc.Name, i+1)
+		}
+		return children
+	}
+}
+
+// overseasElection tells if election has overseas voters.
+func overseasElection(election kpu.Election) bool {
+	return election == kpu.Presidential || election == kpu.DPR
+}
+
+// syntheticLocalParties returns the parties running for a DPRD: the national parties,
+// plus the six Aceh local parties in province 11.
+func syntheticLocalParties(provinceCode string) []string {
+	if kpu.Code(provinceCode) != kpu.Aceh {
+		return syntheticParties
+	}
+
+	return append(append([]string{}, syntheticParties...), "18", "19", "20", "21", "22", "23")
+}
+
+// syntheticCandidatesDPD returns the DPD candidate IDs of a province, four per province.
+func syntheticCandidatesDPD(provinceCode string) []string {
+	return []string{provinceCode + "9001", provinceCode + "9002", provinceCode + "9003", provinceCode + "9004"}
+}
+
+func (g *generator) writeCandidatesDPD(provinceCode string) {
+	candidates := make(map[string]any)
+	for i, id := range syntheticCandidatesDPD(provinceCode) {
+		numericID, _ := strconv.ParseInt(id, 10, 64)
+		candidates[id] = map[string]any{
+			"id":             numericID,
+			"nama":           fmt.Sprintf("CALON DPD %s %d", provinceCode, i+1),
+			"nomor_urut":     i + 1,
+			"jenis_kelamin":  []string{"L", "P"}[i%2],
+			"tempat_tinggal": fmt.Sprintf("SYNTHETIC 1 %s", provinceCode),
+		}
+	}
+
+	g.writeJSON(path.Join("pemilu/caleg/pdpd", provinceCode+".json"), candidates)
+}
+
+// codePath returns the path of the file of code under dir, e.g. dir/73/7371.json, or dir.json for Nationwide.
+func codePath(dir string, code kpu.Code) string {
+	return path.Join(append([]string{dir}, code.PathSegments()...)...) + ".json"
+}
+
+func syntheticDapil(provinceCode string) string {
+	return provinceCode + "01"
+}
+
+func (g *generator) writeDapils(provinces kpu.Locations) {
+	dapils := make([]map[string]any, 0, len(provinces))
+	for i, province := range provinces {
+		if province.Code.IsOverseas() {
+			continue
+		}
+
+		regions := make([]string, 0, g.tree.Cities)
+		for j := 0; j < g.tree.Cities; j++ {
+			regions = append(regions, string(province.Code)+fmt.Sprintf(levels[1].format, levels[1].first+j))
+		}
+
+		dapils = append(dapils, map[string]any{
+			"id":      i + 1,
+			"kode":    syntheticDapil(string(province.Code)),
+			"nama":    strings.Replace(province.Name, "SYNTHETIC 1", "DAPIL", 1) + " I",
+			"wilayah": regions,
+		})
+	}
+
+	g.writeJSON("wilayah/pemilu/pdpr/dapil.json", dapils)
+}
+
+func (g *generator) writeCandidatesDPR(dapil string) {
+	candidates := make(map[string]any, len(syntheticParties))
+	for _, party := range syntheticParties {
+		calegs := make(map[string]any)
+		for i, id := range syntheticCandidatesOf(party) {
+			numericID, _ := strconv.ParseInt(id, 10, 64)
+			calegs[id] = map[string]any{
+				"id":             numericID,
+				"nama":           fmt.Sprintf("CALEG %s PARTAI %s NO %d", dapil, party, i+1),
+				"nomor_urut":     i + 1,
+				"jenis_kelamin":  []string{"L", "P"}[i%2],
+				"tempat_tinggal": fmt.Sprintf("SYNTHETIC 1 %s", dapil[:2]),
+			}
+		}
+		candidates[party] = calegs
+	}
+
+	g.writeJSON(path.Join("pemilu/caleg/partai", dapil+".json"), candidates)
+}
+
+// syntheticCandidatesOf returns the caleg IDs of a party, three per party.
+func syntheticCandidatesOf(party string) []string {
+	return []string{party + "0001", party + "0002", party + "0003"}
+}
+
+func (g *generator) children(parentCode kpu.Code, level int64, n int, format string, first int) kpu.Locations {
+	locations := make(kpu.Locations, n)
+	for i := 0; i < n; i++ {
+		g.id++
+		code := parentCode + kpu.Code(fmt.Sprintf(format, first+i))
+		locations[i] = kpu.Location{
+			Name:  fmt.Sprintf("SYNTHETIC %d %s", level, code),
+			ID:    g.id,
+			Code:  code,
+			Level: level,
+		}
+	}
+
+	return locations
+}
+
+func (g *generator) votes(keys []string, limit int) map[string]int64 {
+	votes := make(map[string]int64, len(keys))
+	for _, key := range keys {
+		votes[key] = int64(g.rand.Intn(limit + 1))
+	}
+
+	return votes
+}
+
+// administrasi returns the administrative block matching chart, or nil for roughly half
+// of the TPS, like during the first days of the count.
+func (g *generator) administrasi(chart map[string]int64) map[string]int64 {
+	if g.rand.Intn(2) == 0 {
+		return nil
+	}
+
+	var valid int64
+	for _, votes := range chart {
+		valid += votes
+	}
+
+	var (
+		invalid    = int64(g.rand.Intn(10))
+		total      = valid + invalid
+		nonDpt     = int64(g.rand.Intn(3))
+		dpt        = total - nonDpt
+		registered = total + int64(g.rand.Intn(100))
+	)
+
+	return map[string]int64{
+		"suara_sah":          valid,
+		"suara_tidak_sah":    invalid,
+		"suara_total":        total,
+		"pemilih_dpt_j":      registered,
+		"pemilih_dpt_l":      registered / 2,
+		"pemilih_dpt_p":      registered - registered/2,
+		"pengguna_dpt_j":     dpt,
+		"pengguna_dpt_l":     dpt / 2,
+		"pengguna_dpt_p":     dpt - dpt/2,
+		"pengguna_dptb_j":    0,
+		"pengguna_dptb_l":    0,
+		"pengguna_dptb_p":    0,
+		"pengguna_non_dpt_j": nonDpt,
+		"pengguna_non_dpt_l": nonDpt / 2,
+		"pengguna_non_dpt_p": nonDpt - nonDpt/2,
+		"pengguna_total_j":   total,
+		"pengguna_total_l":   total / 2,
+		"pengguna_total_p":   total - total/2,
+	}
+}
+
+func (g *generator) writeLocations(name string, locations kpu.Locations) {
+	g.writeJSON(name, locations)
+}
+
+func (g *generator) writeJSON(name string, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("kputest: marshal %s: %v", name, err))
+	}
+
+	g.files[name] = &fstest.MapFile{Data: data}
+}
+
+func addVotes(dest, src map[string]int64) {
+	for key, votes := range src {
+		dest[key] += votes
+	}
+}
+
+func tableRow(votes map[string]int64) map[string]any {
+	row := make(map[string]any, len(votes)+3)
+	for key, v := range votes {
+		row[key] = v
+	}
+
+	row["psu"] = kpu.Reguler
+	row["persen"] = 100.0
+	row["status_progress"] = true
+
+	return row
+}
@@ -0,0 +1,39 @@
+package kpu
+
+import "strings"
+
+// Overseas is the code of the Luar Negeri branch of the location tree. Below it the levels keep
+// their code lengths but not their meaning: a country (negara) at level 2, a PPLN, the committee of
+// an embassy or consulate, at level 3, one group per voting method at level 4, then the polling places.
+// Overseas voters only elect the president and the DPR.
+const Overseas Code = "99"
+
+// IsOverseas tells if c is in the Luar Negeri branch.
+func (c Code) IsOverseas() bool {
+	return c.Province() == Overseas
+}
+
+// VotingMethod is how an overseas polling place collects the ballots.
+type VotingMethod string
+
+const (
+	// MethodTPS is a TPSLN, a polling station voters go to.
+	MethodTPS VotingMethod = "TPS"
+	// MethodKSK is a kotak suara keliling, a ballot box brought to the voters.
+	MethodKSK VotingMethod = "KSK"
+	// MethodPOS is voting by mail.
+	MethodPOS VotingMethod = "POS"
+)
+
+// VotingMethodOf reads the voting method from the name of an overseas location of level 4 or 5,
+// e.g. "KSK 003" or "POS". It is empty when the name tells none.
+func VotingMethodOf(name string) VotingMethod {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	for _, method := range []VotingMethod{MethodKSK, MethodPOS, MethodTPS} {
+		if strings.HasPrefix(name, string(method)) {
+			return method
+		}
+	}
+
+	return ""
+}
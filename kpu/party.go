@@ -0,0 +1,131 @@
+package kpu
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Party is a party on the DPR or DPRD ballot, in the format of https://sirekap-obj-data.kpu.go.id/pemilu/partai.json.
+type Party struct {
+	ID       string `json:"-"`
+	Number   int64  `json:"nomor_urut"`
+	Name     string `json:"nama"`
+	FullName string `json:"nama_lengkap"`
+	Color    string `json:"warna"`
+	// IsAceh marks the local parties, only running for the DPRD in Aceh.
+	IsAceh bool `json:"is_aceh"`
+}
+
+// Aceh is the province where the local parties run for the DPRD next to the national ones.
+const Aceh Code = "11"
+
+// PartyRegistry is the set of known parties keyed by ID. Charts are decoded into maps keyed by the same ID,
+// so a party missing from the registry is still counted, only without a name.
+type PartyRegistry map[string]Party
+
+//go:embed parties.json
+var partiesJSON []byte
+
+// DefaultParties are the parties of the 2024 election, from parties.json. They are the fallback
+// for when the registry can not be fetched, see GetParties.
+var DefaultParties = mustReadParties(partiesJSON)
+
+// ReadParties decodes a registry in the partai.json format.
+func ReadParties(r io.Reader) (PartyRegistry, error) {
+	var parties PartyRegistry
+	err := json.NewDecoder(r).Decode(&parties)
+	if err != nil {
+		return nil, fmt.Errorf("error on decode parties: %w", err)
+	}
+
+	for id, party := range parties {
+		party.ID = id
+		parties[id] = party
+	}
+
+	return parties, nil
+}
+
+// GetParties returns the parties published by Sirekap, https://sirekap-obj-data.kpu.go.id/pemilu/partai.json
+func (s *Sirekap) GetParties(ctx context.Context) (PartyRegistry, error) {
+	parties := make(PartyRegistry)
+	err := s.get(ctx, &parties, "pemilu", "partai")
+	if err != nil {
+		return nil, fmt.Errorf("error on get parties: %w", err)
+	}
+
+	for id, party := range parties {
+		party.ID = id
+		parties[id] = party
+	}
+
+	return parties, nil
+}
+
+func mustReadParties(data []byte) PartyRegistry {
+	parties, err := ReadParties(bytes.NewReader(data))
+	if err != nil {
+		panic(err)
+	}
+
+	return parties
+}
+
+// Label is the short name of the party, or its ID when it is not in the registry.
+func (r PartyRegistry) Label(id string) string {
+	if party, ok := r[id]; ok && party.Name != "" {
+		return party.Name
+	}
+
+	return id
+}
+
+// Ballot returns the IDs of the parties on the ballot in ballot order: the national parties,
+// followed by the local parties of Aceh when aceh is set. Output columns are taken from it rather
+// than from a chart, whose keys may be missing or null before a region is counted.
+func (r PartyRegistry) Ballot(aceh bool) []string {
+	ids := make([]string, 0, len(r))
+	for id, party := range r {
+		if party.IsAceh && !aceh {
+			continue
+		}
+		ids = append(ids, id)
+	}
+
+	sortNumeric(ids)
+
+	sort.SliceStable(ids, func(i, j int) bool {
+		return r[ids[i]].Number < r[ids[j]].Number
+	})
+
+	return ids
+}
+
+// Columns orders the party IDs of a chart for output: by ballot number, then
+// the parties missing from the registry by ID.
+func (r PartyRegistry) Columns(votes map[string]int64) []string {
+	ids := make([]string, 0, len(votes))
+	for id := range votes {
+		ids = append(ids, id)
+	}
+
+	sortNumeric(ids)
+
+	sort.SliceStable(ids, func(i, j int) bool {
+		a, okA := r[ids[i]]
+		b, okB := r[ids[j]]
+		switch {
+		case okA && okB:
+			return a.Number < b.Number
+		default:
+			return okA && !okB
+		}
+	})
+
+	return ids
+}
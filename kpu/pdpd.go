@@ -0,0 +1,81 @@
+package kpu
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// ResponseDataDPDRegion is the DPD aggregate of a region. Unlike DPR, DPD candidates run as individuals
+// for a whole province, so Chart and the rows of Table are keyed by candidate ID, see GetCandidatesDPD.
+// Chart is a TableRow as Sirekap mixes persen into the votes of a region chart.
+type ResponseDataDPDRegion struct {
+	Ts      Time                `json:"ts"`
+	PSU     PSU                 `json:"psu"`
+	Mode    string              `json:"mode"`
+	Chart   TableRow            `json:"chart"`
+	Table   map[string]TableRow `json:"table"`
+	Progres Progres             `json:"progres"`
+}
+
+// GetVotesDPDByRegion returns the DPD aggregate of the province, regency, district or village identified
+// by code, e.g. https://sirekap-obj-data.kpu.go.id/pemilu/hhcw/pdpd/73.json for 73.
+// There is no nationwide DPD aggregate, every province elects its own senators.
+func (s *Sirekap) GetVotesDPDByRegion(ctx context.Context, code Code) (ResponseDataDPDRegion, error) {
+	paths, err := code.segments(LevelProvince, LevelVillage)
+	if err != nil {
+		return ResponseDataDPDRegion{}, err
+	}
+
+	var votes ResponseDataDPDRegion
+	err = s.fetchVotes(ctx, &votes, append([]string{"pdpd"}, paths...)...)
+	if err != nil {
+		return ResponseDataDPDRegion{}, fmt.Errorf("error on fetchVotes: %w", err)
+	}
+
+	return votes, nil
+}
+
+type CandidateDPD struct {
+	ID        int64  `json:"id"`
+	Name      string `json:"nama"`
+	Number    int64  `json:"nomor_urut"`
+	Gender    string `json:"jenis_kelamin"`
+	Residence string `json:"tempat_tinggal"`
+}
+
+// GetCandidatesDPD returns the DPD candidates of a province keyed by candidate ID, e.g.
+// https://sirekap-obj-data.kpu.go.id/pemilu/caleg/pdpd/73.json, see BallotDPD for their order.
+func (s *Sirekap) GetCandidatesDPD(ctx context.Context, provinceCode Code) (map[string]CandidateDPD, error) {
+	paths, err := provinceCode.segments(LevelProvince, LevelProvince)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make(map[string]CandidateDPD)
+	err = s.get(ctx, &candidates, "pemilu/caleg/pdpd", paths...)
+	if err != nil {
+		return nil, fmt.Errorf("error on get candidates DPD: %w", err)
+	}
+
+	return candidates, nil
+}
+
+// BallotDPD returns the IDs of the DPD candidates in ballot order, by ballot number then by ID.
+// Output columns are taken from it rather than from a chart, whose keys may be missing before a region is counted.
+func BallotDPD(candidates map[string]CandidateDPD) []string {
+	ids := make([]string, 0, len(candidates))
+	for id := range candidates {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := candidates[ids[i]].Number, candidates[ids[j]].Number
+		if a != b {
+			return a < b
+		}
+		return ids[i] < ids[j]
+	})
+
+	return ids
+}
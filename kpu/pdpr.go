@@ -0,0 +1,148 @@
+package kpu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// ResponseDataLegislativeRegion is the DPR aggregate of a region, Table holding the per party votes
+// of each child region (of each TPS at village level).
+type ResponseDataLegislativeRegion = ResponseDataLegislativeNationwide
+
+// GetVotesLegislativeByRegion returns the DPR aggregate of the province, regency, district or village
+// identified by code, e.g. https://sirekap-obj-data.kpu.go.id/pemilu/hhcw/pdpr/73/7371.json for 7371.
+// Nationwide returns the nationwide aggregate.
+func (s *Sirekap) GetVotesLegislativeByRegion(ctx context.Context, code Code) (ResponseDataLegislativeRegion, error) {
+	if code == Nationwide {
+		return s.GetVotesLegislativeNationwide(ctx)
+	}
+
+	paths, err := code.segments(LevelProvince, LevelVillage)
+	if err != nil {
+		return ResponseDataLegislativeRegion{}, err
+	}
+
+	var votes ResponseDataLegislativeRegion
+	err = s.fetchVotes(ctx, &votes, append([]string{"pdpr"}, paths...)...)
+	if err != nil {
+		return ResponseDataLegislativeRegion{}, fmt.Errorf("error on fetchVotes: %w", err)
+	}
+
+	return votes, nil
+}
+
+type ResponseDataLegislativeTPS struct {
+	// Chart is keyed by party ID
+	Chart        map[string]PartyVotesTPS `json:"chart"`
+	Images       []string                 `json:"images"`
+	Administrasi *Administrasi            `json:"administrasi"`
+	PSU          *PSU                     `json:"psu"`
+	Ts           Time                     `json:"ts"`
+	StatusSuara  bool                     `json:"status_suara"`
+	StatusAdm    bool                     `json:"status_adm"`
+}
+
+// PartyVotesTPS is the result of one party at one TPS. Besides the two totals, Sirekap puts
+// the votes of every caleg of the party in the same object, keyed by the caleg ID.
+type PartyVotesTPS struct {
+	// Total is the party votes plus the votes of all its caleg.
+	Total int64 `json:"jml_suara_total"`
+	// Party is the votes for the party itself, without a caleg.
+	Party int64 `json:"jml_suara_partai"`
+	// Candidates is keyed by caleg ID.
+	Candidates map[string]int64 `json:"-"`
+}
+
+func (p *PartyVotesTPS) UnmarshalJSON(data []byte) error {
+	var raw map[string]*int64
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+
+	*p = PartyVotesTPS{Candidates: make(map[string]int64, len(raw))}
+	for key, votes := range raw {
+		var value int64
+		if votes != nil {
+			value = *votes
+		}
+
+		switch key {
+		case "jml_suara_total":
+			p.Total = value
+		case "jml_suara_partai":
+			p.Party = value
+		default:
+			p.Candidates[key] = value
+		}
+	}
+
+	return nil
+}
+
+func (p PartyVotesTPS) MarshalJSON() ([]byte, error) {
+	raw := make(map[string]int64, len(p.Candidates)+2)
+	for id, votes := range p.Candidates {
+		raw[id] = votes
+	}
+
+	raw["jml_suara_total"] = p.Total
+	raw["jml_suara_partai"] = p.Party
+
+	return json.Marshal(raw)
+}
+
+// GetVotesLegislativeByTPS returns the DPR result of one TPS, e.g.
+// https://sirekap-obj-data.kpu.go.id/pemilu/hhcw/pdpr/73/7371/737114/7371141006/7371141006002.json
+func (s *Sirekap) GetVotesLegislativeByTPS(ctx context.Context, tpsCode Code) (ResponseDataLegislativeTPS, error) {
+	paths, err := tpsCode.segments(LevelTPS, LevelTPS)
+	if err != nil {
+		return ResponseDataLegislativeTPS{}, err
+	}
+
+	var votes ResponseDataLegislativeTPS
+	err = s.fetchVotes(ctx, &votes, append([]string{"pdpr"}, paths...)...)
+	if err != nil {
+		return ResponseDataLegislativeTPS{}, fmt.Errorf("error on fetchVotes: %w", err)
+	}
+
+	return votes, nil
+}
+
+// PartyIDs returns the IDs of the parties in the chart by numeric ID, which is not the ballot order:
+// use PartyRegistry.Columns to order them for output.
+func (r ResponseDataLegislativeTPS) PartyIDs() []string {
+	ids := make([]string, 0, len(r.Chart))
+	for id := range r.Chart {
+		ids = append(ids, id)
+	}
+
+	sortNumeric(ids)
+
+	return ids
+}
+
+// sortNumeric sorts numeric IDs by value, so "10" comes after "9"; non numeric IDs go last.
+func sortNumeric(ids []string) {
+	less := func(a, b string) bool {
+		na, errA := strconv.Atoi(a)
+		nb, errB := strconv.Atoi(b)
+		switch {
+		case errA == nil && errB == nil:
+			return na < nb
+		case errA == nil:
+			return true
+		case errB == nil:
+			return false
+		default:
+			return a < b
+		}
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		return less(ids[i], ids[j])
+	})
+}
@@ -0,0 +1,40 @@
+package kpu
+
+import (
+	"context"
+	"fmt"
+)
+
+// CandidatePair is a president and vice president pair on the ballot.
+type CandidatePair struct {
+	ID     string `json:"-"`
+	Name   string `json:"nama"`
+	Number int64  `json:"nomor_urut"`
+	Color  string `json:"warna"`
+	Ts     Time   `json:"ts"`
+}
+
+// DefaultCandidatesPresidential are the candidate pairs of the 2024 election, the fallback for when
+// the master data can not be fetched, see GetCandidatesPresidential.
+var DefaultCandidatesPresidential = map[string]CandidatePair{
+	"100025": {ID: "100025", Name: "ANIES RASYID BASWEDAN - MUHAIMIN ISKANDAR", Number: 1},
+	"100026": {ID: "100026", Name: "PRABOWO SUBIANTO - GIBRAN RAKABUMING RAKA", Number: 2},
+	"100027": {ID: "100027", Name: "GANJAR PRANOWO - MAHFUD MD", Number: 3},
+}
+
+// GetCandidatesPresidential returns the candidate pairs keyed by ID, the keys of the presidential charts, from
+// https://sirekap-obj-data.kpu.go.id/pemilu/ppwp.json
+func (s *Sirekap) GetCandidatesPresidential(ctx context.Context) (map[string]CandidatePair, error) {
+	candidates := make(map[string]CandidatePair)
+	err := s.get(ctx, &candidates, "pemilu", "ppwp")
+	if err != nil {
+		return nil, fmt.Errorf("error on get candidates presidential: %w", err)
+	}
+
+	for id, candidate := range candidates {
+		candidate.ID = id
+		candidates[id] = candidate
+	}
+
+	return candidates, nil
+}
@@ -0,0 +1,139 @@
+package kpu
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimit is a token bucket shared by every request of a Sirekap client, retries included.
+type RateLimit struct {
+	// PerSecond is the sustained number of requests per second. Zero or less disables the limit.
+	PerSecond float64
+	// Burst is the number of requests allowed at once before PerSecond applies. Values below 1 mean 1.
+	Burst int
+}
+
+// DefaultRateLimit keeps a full crawl polite to KPU while still finishing within the hourly schedule.
+var DefaultRateLimit = RateLimit{
+	PerSecond: 10,
+	Burst:     20,
+}
+
+// WithRateLimit replaces DefaultRateLimit.
+func WithRateLimit(limit RateLimit) Option {
+	return func(s *Sirekap) {
+		s.limiter = newRateLimiter(limit)
+	}
+}
+
+// LimiterStats reports how much the rate limiter slowed down the callers.
+type LimiterStats struct {
+	Requests int64
+	Delayed  int64
+	Waited   time.Duration
+}
+
+// LimiterStats returns the accumulated rate limiter statistics since the client was created.
+func (s *Sirekap) LimiterStats() LimiterStats {
+	if s.limiter == nil {
+		return LimiterStats{}
+	}
+
+	s.limiter.mu.Lock()
+	defer s.limiter.mu.Unlock()
+
+	return s.limiter.stats
+}
+
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	stats  LimiterStats
+}
+
+func newRateLimiter(limit RateLimit) *rateLimiter {
+	if limit.PerSecond <= 0 {
+		return nil
+	}
+
+	burst := float64(limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &rateLimiter{
+		rate:   limit.PerSecond,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// reserve takes a token and returns how long the caller has to wait before using it.
+// The bucket may go negative, which queues callers in arrival order.
+func (l *rateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens--
+
+	l.stats.Requests++
+	if l.tokens >= 0 {
+		return 0
+	}
+
+	delay := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.stats.Delayed++
+	l.stats.Waited += delay
+
+	return delay
+}
+
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	delay := l.reserve()
+	if delay <= 0 {
+		return nil
+	}
+
+	reserved := time.Now()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		l.cancel(delay - time.Since(reserved))
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// cancel gives back the token of a caller that stopped waiting for it, so that the callers
+// that come next are not delayed for a request never sent, and Waited only counts the time actually waited.
+func (l *rateLimiter) cancel(unused time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tokens++
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+
+	if unused > 0 {
+		l.stats.Waited -= unused
+	}
+}
@@ -0,0 +1,36 @@
+package kpu
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterWaitCancelled(t *testing.T) {
+	limiter := newRateLimiter(RateLimit{PerSecond: 1, Burst: 1})
+
+	err := limiter.wait(context.Background())
+	if err != nil {
+		t.Fatalf("first request: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err = limiter.wait(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("wait = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	// the cancelled request gave its token back: the bucket is where the first request left it
+	if limiter.tokens < -0.1 {
+		t.Errorf("tokens = %f, the cancelled token was not given back", limiter.tokens)
+	}
+	if limiter.stats.Waited > 500*time.Millisecond {
+		t.Errorf("Waited = %s, want about the 10ms actually waited", limiter.stats.Waited)
+	}
+}
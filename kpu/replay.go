@@ -0,0 +1,110 @@
+package kpu
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrNotArchived is returned by ReplayTransport for a URL missing from the loaded archives.
+var ErrNotArchived = errors.New("sirekap: response not archived")
+
+// ReplayTransport answers requests from archived responses instead of the network.
+// Records are matched on the URL path only, so a replay works whatever host the client uses.
+// When a path was archived several times, the latest record wins.
+type ReplayTransport struct {
+	mu      sync.Mutex
+	records map[string]ArchiveRecord
+}
+
+func NewReplayTransport() *ReplayTransport {
+	return &ReplayTransport{
+		records: make(map[string]ArchiveRecord),
+	}
+}
+
+// Add makes record the answer for its path, unless a more recent record is already known.
+func (t *ReplayTransport) Add(record ArchiveRecord) error {
+	req, err := http.NewRequest(http.MethodGet, record.URL, nil)
+	if err != nil {
+		return fmt.Errorf("error on parse archived URL: %w", err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if known, ok := t.records[req.URL.Path]; ok && known.FetchedAt.After(record.FetchedAt) {
+		return nil
+	}
+
+	t.records[req.URL.Path] = record
+
+	return nil
+}
+
+// LoadArchive adds the records of the archive at path fetched at or before until.
+// A zero until loads every record.
+func (t *ReplayTransport) LoadArchive(path string, until time.Time) error {
+	return ReadArchive(path, func(record ArchiveRecord) error {
+		if !until.IsZero() && record.FetchedAt.After(until) {
+			return nil
+		}
+
+		return t.Add(record)
+	})
+}
+
+// Len returns the number of distinct paths that can be replayed.
+func (t *ReplayTransport) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return len(t.records)
+}
+
+// NotModified reports whether the archived response for rawURL had been served after a 304.
+func (t *ReplayTransport) NotModified(rawURL string) bool {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.records[req.URL.Path].NotModified
+}
+
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	record, ok := t.records[req.URL.Path]
+	t.mu.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotArchived, req.URL.String())
+	}
+
+	header := make(http.Header)
+	header.Set("Content-Type", "application/json")
+	header.Set("Content-Length", strconv.Itoa(len(record.Body)))
+	if record.NotModified {
+		header.Set(HeaderNotModified, "1")
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", record.StatusCode, http.StatusText(record.StatusCode)),
+		StatusCode:    record.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(record.Body)),
+		ContentLength: int64(len(record.Body)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,131 @@
+package kpu
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how a failed Sirekap request is retried.
+// Only transient failures are retried: network errors, 408, 429 and 5xx responses.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one. Values below 1 mean 1.
+	MaxAttempts int
+	// BaseDelay is the wait before the second attempt, multiplied by Multiplier on every next attempt.
+	BaseDelay  time.Duration
+	Multiplier float64
+	// MaxDelay caps both the backoff and a Retry-After sent by the server.
+	MaxDelay time.Duration
+	// Jitter randomizes each delay by up to this fraction, e.g. 0.2 means ±20%.
+	Jitter float64
+}
+
+var (
+	DefaultRetryPolicy = RetryPolicy{
+		MaxAttempts: 4,
+		BaseDelay:   500 * time.Millisecond,
+		Multiplier:  2,
+		MaxDelay:    30 * time.Second,
+		Jitter:      0.2,
+	}
+
+	NoRetry = RetryPolicy{MaxAttempts: 1}
+)
+
+// WithRetry replaces DefaultRetryPolicy.
+func WithRetry(policy RetryPolicy) Option {
+	return func(s *Sirekap) {
+		s.retry = policy
+	}
+}
+
+// backoff returns the wait before attempt+1, attempt being the one that just failed.
+func (p RetryPolicy) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	delay := time.Duration(float64(p.BaseDelay) * math.Pow(multiplier, float64(attempt-1)))
+	if p.Jitter > 0 {
+		delay += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(delay))
+	}
+
+	if retryAfter > delay {
+		delay = retryAfter
+	}
+
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	return delay
+}
+
+func (p RetryPolicy) shouldRetry(attempt int, method string, err error) bool {
+	if attempt >= p.MaxAttempts {
+		return false
+	}
+
+	if method != http.MethodGet && method != http.MethodHead {
+		return false
+	}
+
+	// only the caller cancels, see fetch for its deadline. A DeadlineExceeded of its own is the
+	// timeout of the attempt, see WithRequestTimeout, and retried like any other network error.
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var upstreamErr *UpstreamError
+	if !errors.As(err, &upstreamErr) {
+		// connection reset, timeout, DNS...
+		return true
+	}
+
+	switch {
+	case upstreamErr.StatusCode == http.StatusRequestTimeout,
+		upstreamErr.StatusCode == http.StatusTooManyRequests,
+		upstreamErr.StatusCode >= 500:
+		return true
+	case upstreamErr.Kind == ErrUnavailable && upstreamErr.Err != nil:
+		// body was cut off while reading
+		return true
+	default:
+		return false
+	}
+}
+
+// parseRetryAfter supports both forms of the header: delay in seconds and HTTP date.
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(header); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+
+	return 0
+}
+
+type attemptKey struct{}
+
+// Attempt returns the 1-based attempt number of a Sirekap request, for use in an http.RoundTripper.
+// It returns 0 for requests not made by Sirekap.
+func Attempt(ctx context.Context) int {
+	attempt, _ := ctx.Value(attemptKey{}).(int)
+	return attempt
+}
+
+func withAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, attemptKey{}, attempt)
+}
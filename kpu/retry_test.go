@@ -0,0 +1,143 @@
+package kpu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts: 5,
+		BaseDelay:   100 * time.Millisecond,
+		Multiplier:  2,
+		MaxDelay:    time.Second,
+	}
+
+	tests := []struct {
+		attempt    int
+		retryAfter time.Duration
+		want       time.Duration
+	}{
+		{attempt: 1, want: 100 * time.Millisecond},
+		{attempt: 2, want: 200 * time.Millisecond},
+		{attempt: 3, want: 400 * time.Millisecond},
+		{attempt: 5, want: time.Second},
+		{attempt: 1, retryAfter: 300 * time.Millisecond, want: 300 * time.Millisecond},
+		{attempt: 1, retryAfter: time.Minute, want: time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := policy.backoff(tt.attempt, tt.retryAfter); got != tt.want {
+			t.Errorf("backoff(%d, %s) = %s, want %s", tt.attempt, tt.retryAfter, got, tt.want)
+		}
+	}
+}
+
+func TestRetryPolicyBackoffJitter(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, Multiplier: 2, Jitter: 0.2}
+
+	for i := 0; i < 100; i++ {
+		got := policy.backoff(2, 0)
+		if got < 160*time.Millisecond || got > 240*time.Millisecond {
+			t.Fatalf("backoff(2, 0) = %s, want 200ms ±20%%", got)
+		}
+	}
+}
+
+func TestRetryPolicyShouldRetry(t *testing.T) {
+	upstream := func(kind error, statusCode int, err error) error {
+		return fmt.Errorf("error on fetch: %w", &UpstreamError{Kind: kind, StatusCode: statusCode, Err: err})
+	}
+
+	tests := []struct {
+		name    string
+		attempt int
+		method  string
+		err     error
+		want    bool
+	}{
+		{name: "network error", attempt: 1, method: http.MethodGet, err: errors.New("connection reset"), want: true},
+		{name: "5xx", attempt: 1, method: http.MethodGet, err: upstream(ErrUnavailable, http.StatusBadGateway, nil), want: true},
+		{name: "429", attempt: 1, method: http.MethodGet, err: upstream(ErrRateLimited, http.StatusTooManyRequests, nil), want: true},
+		{name: "408", attempt: 1, method: http.MethodHead, err: upstream(ErrUnavailable, http.StatusRequestTimeout, nil), want: true},
+		{name: "body cut off", attempt: 1, method: http.MethodGet, err: upstream(ErrUnavailable, http.StatusOK, io.ErrUnexpectedEOF), want: true},
+		{name: "403", attempt: 1, method: http.MethodGet, err: upstream(ErrRateLimited, http.StatusForbidden, nil), want: false},
+		{name: "404", attempt: 1, method: http.MethodGet, err: upstream(ErrNotFound, http.StatusNotFound, nil), want: false},
+		{name: "malformed body", attempt: 1, method: http.MethodGet, err: upstream(ErrMalformedBody, http.StatusOK, errors.New("bad json")), want: false},
+		{name: "cancelled", attempt: 1, method: http.MethodGet, err: context.Canceled, want: false},
+		{name: "attempt timeout", attempt: 1, method: http.MethodGet, err: fmt.Errorf("error on http get: %w", context.DeadlineExceeded), want: true},
+		{name: "not idempotent", attempt: 1, method: http.MethodPost, err: errors.New("connection reset"), want: false},
+		{name: "last attempt", attempt: 4, method: http.MethodGet, err: errors.New("connection reset"), want: false},
+	}
+
+	for _, tt := range tests {
+		if got := DefaultRetryPolicy.shouldRetry(tt.attempt, tt.method, tt.err); got != tt.want {
+			t.Errorf("%s: shouldRetry = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 2, 25, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{header: "", want: 0},
+		{header: "3", want: 3 * time.Second},
+		{header: "-1", want: 0},
+		{header: now.Add(90 * time.Second).Format(http.TimeFormat), want: 90 * time.Second},
+		{header: now.Add(-time.Minute).Format(http.TimeFormat), want: 0},
+		{header: "soon", want: 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.header, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestSirekapRetryRequestTimeout(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			// hang past the timeout of the attempt
+			<-r.Context().Done()
+			return
+		}
+		w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+
+	sirekap := NewSirekap(srv.Client(),
+		WithHost(srv.URL),
+		WithRateLimit(RateLimit{}),
+		WithRetry(RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}),
+		WithRequestTimeout(50*time.Millisecond),
+	)
+
+	var provinces Locations
+	err := sirekap.FetchLocations(context.Background(), &provinces, Nationwide)
+	if err != nil {
+		t.Fatalf("FetchLocations after a timed out attempt: %v", err)
+	}
+
+	// the deadline of the caller is final
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	atomic.StoreInt32(&requests, 0)
+	err = sirekap.FetchLocations(ctx, &provinces, Nationwide)
+	if n := atomic.LoadInt32(&requests); !errors.Is(err, context.DeadlineExceeded) || n != 1 {
+		t.Errorf("FetchLocations past the deadline of the caller: %v after %d requests, want %v after 1", err, n, context.DeadlineExceeded)
+	}
+}
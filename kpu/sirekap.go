@@ -1,51 +1,135 @@
 package kpu
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"time"
 )
 
 type ResponseDataTPS struct {
-	Chart        map[string]int64 `json:"chart"`
-	Images       []string         `json:"images"`
-	Administrasi interface{}      `json:"administrasi"`
-	PSU          interface{}      `json:"psu"`
-	Ts           string           `json:"ts"`
-	StatusSuara  bool             `json:"status_suara"`
-	StatusAdm    bool             `json:"status_adm"`
+	Chart  map[string]int64 `json:"chart"`
+	Images []string         `json:"images"`
+	// Administrasi is nil until the administrative part of the C1 form has been digitized.
+	Administrasi *Administrasi `json:"administrasi"`
+	// PSU is nil for most TPS, it is only set when the vote has been (re)held, e.g. Reguler.
+	PSU         *PSU `json:"psu"`
+	Ts          Time `json:"ts"`
+	StatusSuara bool `json:"status_suara"`
+	StatusAdm   bool `json:"status_adm"`
+}
+
+// Administrasi is the administrative data of a TPS C1 form. Suffix _j is the total,
+// _l the male and _p the female voters.
+type Administrasi struct {
+	SuaraSah      int64 `json:"suara_sah"`
+	SuaraTidakSah int64 `json:"suara_tidak_sah"`
+	SuaraTotal    int64 `json:"suara_total"`
+
+	// registered voters (DPT)
+	PemilihDptJ int64 `json:"pemilih_dpt_j"`
+	PemilihDptL int64 `json:"pemilih_dpt_l"`
+	PemilihDptP int64 `json:"pemilih_dpt_p"`
+
+	// voters who used their right: registered (DPT), additional (DPTb) and without registration (non DPT)
+	PenggunaDptJ    int64 `json:"pengguna_dpt_j"`
+	PenggunaDptL    int64 `json:"pengguna_dpt_l"`
+	PenggunaDptP    int64 `json:"pengguna_dpt_p"`
+	PenggunaDptbJ   int64 `json:"pengguna_dptb_j"`
+	PenggunaDptbL   int64 `json:"pengguna_dptb_l"`
+	PenggunaDptbP   int64 `json:"pengguna_dptb_p"`
+	PenggunaNonDptJ int64 `json:"pengguna_non_dpt_j"`
+	PenggunaNonDptL int64 `json:"pengguna_non_dpt_l"`
+	PenggunaNonDptP int64 `json:"pengguna_non_dpt_p"`
+	PenggunaTotalJ  int64 `json:"pengguna_total_j"`
+	PenggunaTotalL  int64 `json:"pengguna_total_l"`
+	PenggunaTotalP  int64 `json:"pengguna_total_p"`
+}
+
+// Turnout is the share of registered voters who voted. ok is false when there is no DPT to divide by.
+func (a Administrasi) Turnout() (turnout float64, ok bool) {
+	if a.PemilihDptJ <= 0 {
+		return 0, false
+	}
+
+	return float64(a.PenggunaTotalJ) / float64(a.PemilihDptJ), true
+}
+
+// InvalidRate is the share of invalid ballots among the ballots cast. ok is false when no ballot was counted.
+func (a Administrasi) InvalidRate() (rate float64, ok bool) {
+	if a.SuaraTotal <= 0 {
+		return 0, false
+	}
+
+	return float64(a.SuaraTidakSah) / float64(a.SuaraTotal), true
 }
 
 type Location struct {
 	Name  string `json:"nama"`
 	ID    int64  `json:"id"`
-	Code  string `json:"kode"`
+	Code  Code   `json:"kode"`
 	Level int64  `json:"tingkat"`
 }
 
 type Locations []Location
 
 type Sirekap struct {
-	host string
-	http *http.Client
+	host           string
+	http           *http.Client
+	retry          RetryPolicy
+	limiter        *rateLimiter
+	requestTimeout time.Duration
+	cache          *DiskCache
+	archive        *ArchiveWriter
+	drift          *DriftReport
+}
+
+const DefaultHost = "https://sirekap-obj-data.kpu.go.id"
+
+// Option configures optional behaviour of Sirekap.
+type Option func(*Sirekap)
+
+// WithHost overrides the Sirekap object storage host, e.g. to point at a mirror or a kputest server.
+func WithHost(host string) Option {
+	return func(s *Sirekap) {
+		s.host = host
+	}
+}
+
+// WithRequestTimeout bounds every single attempt, on top of any deadline of the caller's context.
+func WithRequestTimeout(timeout time.Duration) Option {
+	return func(s *Sirekap) {
+		s.requestTimeout = timeout
+	}
 }
 
-func NewSirekap(httpClient *http.Client) *Sirekap {
-	return &Sirekap{
-		host: "https://sirekap-obj-data.kpu.go.id",
-		http: httpClient,
+func NewSirekap(httpClient *http.Client, opts ...Option) *Sirekap {
+	s := &Sirekap{
+		host:    DefaultHost,
+		http:    httpClient,
+		retry:   DefaultRetryPolicy,
+		limiter: newRateLimiter(DefaultRateLimit),
 	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
 }
 
-func (s *Sirekap) GetVotesByTPS(tpsCode string) (ResponseDataTPS, error) {
-	if len(tpsCode) != 13 {
-		return ResponseDataTPS{}, errors.New("invalid code TPS, expect 13 chars")
+func (s *Sirekap) GetVotesByTPS(ctx context.Context, tpsCode Code) (ResponseDataTPS, error) {
+	paths, err := tpsCode.segments(LevelTPS, LevelTPS)
+	if err != nil {
+		return ResponseDataTPS{}, err
 	}
 
 	var votes ResponseDataTPS
-	err := s.fetchVotes(&votes, tpsCode[0:2], tpsCode[0:4], tpsCode[0:6], tpsCode[0:10], tpsCode)
+	err = s.fetchVotes(ctx, &votes, append([]string{"ppwp"}, paths...)...)
 	if err != nil {
 		return ResponseDataTPS{}, fmt.Errorf("error on fetchVotes: %w", err)
 	}
@@ -53,66 +137,221 @@ func (s *Sirekap) GetVotesByTPS(tpsCode string) (ResponseDataTPS, error) {
 	return votes, nil
 }
 
-func (s *Sirekap) FetchLocations(dest *Locations, dynamicPaths ...string) error {
-	basePathLocation, err := url.JoinPath(s.host, "wilayah/pemilu/ppwp")
+// FetchLocations fetches the children of the location identified by parent, e.g.
+// https://sirekap-obj-data.kpu.go.id/wilayah/pemilu/ppwp/73/7371.json for the districts of 7371.
+// The provinces are the children of Nationwide.
+func (s *Sirekap) FetchLocations(ctx context.Context, dest *Locations, parent Code) error {
+	paths, err := parent.segments(LevelNationwide, LevelVillage)
+	if err != nil {
+		return err
+	}
+
+	if parent == Nationwide {
+		paths = []string{"0"}
+	}
+
+	return s.get(ctx, dest, "wilayah/pemilu/ppwp", paths...)
+}
+
+func (s *Sirekap) fetchVotes(ctx context.Context, dest any, dynamicPaths ...string) error {
+	// "https://sirekap-obj-data.kpu.go.id/pemilu/hhcw/ppwp/73/7371/737114/7371141006/7371141006002.json"
+	return s.get(ctx, dest, "pemilu/hhcw", dynamicPaths...)
+}
+
+// VotesURL returns the URL fetched for the vote paths, e.g. VotesURL("ppwp") for ppwp.json.
+func (s *Sirekap) VotesURL(dynamicPaths ...string) (string, error) {
+	return s.url("pemilu/hhcw", dynamicPaths...)
+}
+
+func (s *Sirekap) url(basePath string, dynamicPaths ...string) (string, error) {
+	base, err := url.JoinPath(s.host, basePath)
+	if err != nil {
+		return "", fmt.Errorf("error on build base path %s: %w", basePath, err)
+	}
+
+	source, err := url.JoinPath(base, dynamicPaths...)
 	if err != nil {
-		return fmt.Errorf("error on build base path locations: %w", err)
+		return "", fmt.Errorf("error on build full URL: %w", err)
 	}
 
-	source, err := url.JoinPath(basePathLocation, dynamicPaths...)
+	return fmt.Sprintf("%s.%s", source, "json"), nil
+}
+
+func (s *Sirekap) get(ctx context.Context, dest any, basePath string, dynamicPaths ...string) error {
+	source, err := s.url(basePath, dynamicPaths...)
 	if err != nil {
-		return fmt.Errorf("error on build full URL: %w", err)
+		return err
 	}
 
-	resp, err := s.http.Get(fmt.Sprintf("%s.%s", source, "json"))
+	if body, ok := s.cache.get(basePath, source); ok {
+		// a corrupted entry is simply fetched again
+		if json.Unmarshal(body, dest) == nil {
+			// the run used the response all the same, so it is archived and checked like a fetched one
+			s.archive.record(source, http.StatusOK, false, body)
+			s.drift.check(source, basePath, dynamicPaths, body, dest)
+			return nil
+		}
+	}
+
+	resp, err := s.fetch(ctx, source, nil)
 	if err != nil {
-		return fmt.Errorf("error on http get: %w", err)
+		return err
 	}
 
-	defer resp.Body.Close()
+	body := resp.body
+	err = json.Unmarshal(body, dest)
+
+	// the strict mode records the drift that broke the decoding as well, it only needs the type of dest
+	s.drift.check(source, basePath, dynamicPaths, body, dest)
 
-	err = json.NewDecoder(resp.Body).Decode(dest)
 	if err != nil {
-		return fmt.Errorf("error on decode response: %w", err)
+		return &UpstreamError{Kind: ErrMalformedBody, URL: source, StatusCode: resp.statusCode, Body: bodyExcerpt(body), Err: err}
 	}
 
+	s.cache.put(source, body)
+
 	return nil
 }
 
-func (s *Sirekap) fetchVotes(dest any, dynamicPaths ...string) error {
-	// "https://sirekap-obj-data.kpu.go.id/pemilu/hhcw/ppwp/73/7371/737114/7371141006/7371141006002.json"
-	basePathVote, err := url.JoinPath(s.host, "pemilu/hhcw")
+// FetchFile downloads rawURL as is, with the retry policy and rate limit of s, e.g. a C1 scan.
+// The response is archived like any other, so use a client without archive for binary files.
+func (s *Sirekap) FetchFile(ctx context.Context, rawURL string) ([]byte, error) {
+	resp, err := s.fetch(ctx, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.body, nil
+}
+
+// Validators are the ETag and Last-Modified of a response, sent back to revalidate it.
+type Validators struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// FetchFileIfModified is FetchFile revalidating known: it sends its ETag and Last-Modified as
+// If-None-Match and If-Modified-Since, and a 304 comes back as notModified without a body.
+// Otherwise it returns the body with its own validators.
+func (s *Sirekap) FetchFileIfModified(ctx context.Context, rawURL string, known Validators) (
+	body []byte, validators Validators, notModified bool, err error,
+) {
+	header := make(http.Header)
+	if known.ETag != "" {
+		header.Set("If-None-Match", known.ETag)
+	}
+	if known.LastModified != "" {
+		header.Set("If-Modified-Since", known.LastModified)
+	}
+
+	resp, err := s.fetch(ctx, rawURL, header)
 	if err != nil {
-		return fmt.Errorf("error on build base path votes: %w", err)
+		return nil, Validators{}, false, err
+	}
+
+	if resp.statusCode == http.StatusNotModified {
+		return nil, known, true, nil
+	}
+
+	validators = Validators{ETag: resp.header.Get("ETag"), LastModified: resp.header.Get("Last-Modified")}
+
+	return resp.body, validators, false, nil
+}
+
+// response is what request keeps of a response.
+type response struct {
+	body       []byte
+	statusCode int
+	header     http.Header
+}
+
+// fetch downloads source with the request header, retrying transient failures according to the retry policy.
+func (s *Sirekap) fetch(ctx context.Context, source string, header http.Header) (response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := s.request(ctx, source, header, attempt)
+		// a deadline of the caller ends the fetch, the timeout of the attempt alone is retried: only ctx tells them apart
+		if err == nil || ctx.Err() != nil || !s.retry.shouldRetry(attempt, http.MethodGet, err) {
+			return resp, err
+		}
+
+		var retryAfter time.Duration
+		var upstreamErr *UpstreamError
+		if errors.As(err, &upstreamErr) {
+			retryAfter = upstreamErr.RetryAfter
+		}
+
+		timer := time.NewTimer(s.retry.backoff(attempt, retryAfter))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return response{}, fmt.Errorf("%w (last attempt: %v)", ctx.Err(), err)
+		case <-timer.C:
+		}
+	}
+}
+
+// request sends one attempt of fetch. A 304 is only a success when header makes the request conditional.
+func (s *Sirekap) request(ctx context.Context, source string, header http.Header, attempt int) (response, error) {
+	if err := s.limiter.wait(ctx); err != nil {
+		return response{}, err
+	}
+
+	if s.requestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.requestTimeout)
+		defer cancel()
 	}
 
-	source, err := url.JoinPath(basePathVote, dynamicPaths...)
+	req, err := http.NewRequestWithContext(withAttempt(ctx, attempt), http.MethodGet, source, nil)
 	if err != nil {
-		return fmt.Errorf("error on build full URL: %w", err)
+		return response{}, fmt.Errorf("error on build request: %w", err)
 	}
 
-	resp, err := s.http.Get(fmt.Sprintf("%s.%s", source, "json"))
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	resp, err := s.http.Do(req)
 	if err != nil {
-		return fmt.Errorf("error on http get: %w", err)
+		return response{}, fmt.Errorf("error on http get: %w", err)
 	}
 
 	defer resp.Body.Close()
 
-	err = json.NewDecoder(resp.Body).Decode(dest)
+	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("error on decode response: %w", err)
+		return response{statusCode: resp.StatusCode}, &UpstreamError{Kind: ErrUnavailable, URL: source, StatusCode: resp.StatusCode, Err: err}
 	}
 
-	return nil
+	s.archive.record(source, resp.StatusCode, resp.Header.Get(HeaderNotModified) != "", body)
+
+	result := response{body: body, statusCode: resp.StatusCode, header: resp.Header}
+
+	conditional := req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != ""
+	if resp.StatusCode == http.StatusNotModified && conditional {
+		return result, nil
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return result, &UpstreamError{
+			Kind:       errorKind(resp.StatusCode),
+			URL:        source,
+			StatusCode: resp.StatusCode,
+			Body:       bodyExcerpt(body),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
+	}
+
+	return result, nil
 }
 
 type ResponseDataPresidentialNationwide struct {
-	Ts      string             `json:"ts"`
-	PSU     PSU                `json:"psu"`
-	Mode    string             `json:"mode"`
-	Chart   map[string]float64 `json:"chart"`
-	Table   map[string]Table   `json:"table"`
-	Progres Progres            `json:"progres"`
+	Ts      Time                `json:"ts"`
+	PSU     PSU                 `json:"psu"`
+	Mode    string              `json:"mode"`
+	Chart   map[string]float64  `json:"chart"`
+	Table   map[string]TableRow `json:"table"`
+	Progres Progres             `json:"progres"`
 }
 
 type Progres struct {
@@ -120,25 +359,30 @@ type Progres struct {
 	Progres int64 `json:"progres"`
 }
 
-type Table struct {
-	The100025      *int64  `json:"100025,omitempty"`
-	The100026      *int64  `json:"100026,omitempty"`
-	The100027      *int64  `json:"100027,omitempty"`
-	PSU            PSU     `json:"psu"`
-	Persen         float64 `json:"persen"`
-	StatusProgress bool    `json:"status_progress"`
-}
-
 type PSU string
 
 const (
 	Reguler PSU = "Reguler"
 )
 
+// KnownPSU are the psu values the output handles, anything else is reported by the strict mode.
+var KnownPSU = []PSU{Reguler}
+
+// Known tells if p is one of KnownPSU.
+func (p PSU) Known() bool {
+	for _, known := range KnownPSU {
+		if p == known {
+			return true
+		}
+	}
+
+	return false
+}
+
 // https://sirekap-obj-data.kpu.go.id/pemilu/hhcw/ppwp.json
-func (s *Sirekap) GetVotesPresidentialNationwide() (ResponseDataPresidentialNationwide, error) {
+func (s *Sirekap) GetVotesPresidentialNationwide(ctx context.Context) (ResponseDataPresidentialNationwide, error) {
 	var votes ResponseDataPresidentialNationwide
-	err := s.fetchVotes(&votes, "ppwp")
+	err := s.fetchVotes(ctx, &votes, "ppwp")
 	if err != nil {
 		return ResponseDataPresidentialNationwide{}, fmt.Errorf("error on fetchVotes: %w", err)
 	}
@@ -146,43 +390,48 @@ func (s *Sirekap) GetVotesPresidentialNationwide() (ResponseDataPresidentialNati
 	return votes, nil
 }
 
+// ResponseDataPresidentialRegion is the presidential aggregate of a region, Table holding one row
+// per child region (per TPS at village level). The nationwide response is the region at the root.
+type ResponseDataPresidentialRegion = ResponseDataPresidentialNationwide
+
+// GetVotesPresidentialByRegion returns the presidential aggregate of the province, regency, district
+// or village identified by code, e.g.
+// https://sirekap-obj-data.kpu.go.id/pemilu/hhcw/ppwp/73/7371/737114.json for 737114.
+// Nationwide returns the nationwide aggregate.
+func (s *Sirekap) GetVotesPresidentialByRegion(ctx context.Context, code Code) (ResponseDataPresidentialRegion, error) {
+	if code == Nationwide {
+		return s.GetVotesPresidentialNationwide(ctx)
+	}
+
+	paths, err := code.segments(LevelProvince, LevelVillage)
+	if err != nil {
+		return ResponseDataPresidentialRegion{}, err
+	}
+
+	var votes ResponseDataPresidentialRegion
+	err = s.fetchVotes(ctx, &votes, append([]string{"ppwp"}, paths...)...)
+	if err != nil {
+		return ResponseDataPresidentialRegion{}, fmt.Errorf("error on fetchVotes: %w", err)
+	}
+
+	return votes, nil
+}
+
+// ResponseDataLegislativeNationwide is the DPR aggregate, Chart and every row of Table keyed by party ID,
+// see PartyRegistry.
 type ResponseDataLegislativeNationwide struct {
-	Ts      string           `json:"ts"`
-	PSU     PSU              `json:"psu"`
-	Mode    string           `json:"mode"`
-	Chart   Chart            `json:"chart"`
-	Table   map[string]Chart `json:"table"`
-	Progres Progres          `json:"progres"`
-}
-
-type Chart struct {
-	The1           int64    `json:"1"`
-	The2           int64    `json:"2"`
-	The3           int64    `json:"3"`
-	The4           int64    `json:"4"`
-	The5           int64    `json:"5"`
-	The6           int64    `json:"6"`
-	The7           int64    `json:"7"`
-	The8           int64    `json:"8"`
-	The9           int64    `json:"9"`
-	The10          int64    `json:"10"`
-	The11          int64    `json:"11"`
-	The12          int64    `json:"12"`
-	The13          int64    `json:"13"`
-	The14          int64    `json:"14"`
-	The15          int64    `json:"15"`
-	The16          int64    `json:"16"`
-	The17          int64    `json:"17"`
-	The24          int64    `json:"24"`
-	PSU            *PSU     `json:"psu,omitempty"`
-	Persen         *float64 `json:"persen,omitempty"`
-	StatusProgress *bool    `json:"status_progress,omitempty"`
+	Ts      Time                `json:"ts"`
+	PSU     PSU                 `json:"psu"`
+	Mode    string              `json:"mode"`
+	Chart   TableRow            `json:"chart"`
+	Table   map[string]TableRow `json:"table"`
+	Progres Progres             `json:"progres"`
 }
 
 // https://sirekap-obj-data.kpu.go.id/pemilu/hhcw/pdpr.json
-func (s *Sirekap) GetVotesLegislativeNationwide() (ResponseDataLegislativeNationwide, error) {
+func (s *Sirekap) GetVotesLegislativeNationwide(ctx context.Context) (ResponseDataLegislativeNationwide, error) {
 	var votes ResponseDataLegislativeNationwide
-	err := s.fetchVotes(&votes, "pdpr")
+	err := s.fetchVotes(ctx, &votes, "pdpr")
 	if err != nil {
 		return ResponseDataLegislativeNationwide{}, fmt.Errorf("error on fetchVotes: %w", err)
 	}
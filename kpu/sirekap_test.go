@@ -0,0 +1,124 @@
+package kpu_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pararang/pemilu2024/kpu"
+	"github.com/pararang/pemilu2024/kpu/kputest"
+)
+
+func TestSirekapCrawl(t *testing.T) {
+	srv := kputest.NewSyntheticServer(kputest.DefaultTree)
+	defer srv.Close()
+
+	sirekap := srv.Sirekap()
+	ctx := context.Background()
+
+	// every level of the domestic tree, province first; the Luar Negeri branch has its own shape
+	counts := make([]int, kpu.LevelTPS+1)
+	var crawl func(parent kpu.Code)
+	crawl = func(parent kpu.Code) {
+		var children kpu.Locations
+		err := sirekap.FetchLocations(ctx, &children, parent)
+		if err != nil {
+			t.Fatalf("FetchLocations %q: %v", parent, err)
+		}
+
+		for _, child := range children {
+			if child.Code.IsOverseas() {
+				continue
+			}
+			if child.Code.Parent() != parent || child.Code.Level() != int(child.Level) {
+				t.Fatalf("child %+v of %q", child, parent)
+			}
+
+			counts[child.Level]++
+			if child.Level < kpu.LevelTPS {
+				crawl(child.Code)
+			} else if _, err := sirekap.GetVotesByTPS(ctx, child.Code); err != nil {
+				t.Fatalf("GetVotesByTPS %s: %v", child.Code, err)
+			}
+		}
+	}
+	crawl(kpu.Nationwide)
+
+	tree := kputest.DefaultTree
+	want := []int{0, tree.Provinces}
+	for _, n := range []int{tree.Cities, tree.Districts, tree.Villages, tree.TPS} {
+		want = append(want, want[len(want)-1]*n)
+	}
+	for level, count := range counts {
+		if count != want[level] {
+			t.Errorf("level %d: %d locations, want %d", level, count, want[level])
+		}
+	}
+
+	_, err := sirekap.GetVotesByTPS(ctx, "9999999999999")
+	if !errors.Is(err, kpu.ErrNotFound) {
+		t.Errorf("GetVotesByTPS of an unknown TPS: error = %v, want %v", err, kpu.ErrNotFound)
+	}
+
+	_, err = sirekap.GetVotesByTPS(ctx, "7371")
+	if !errors.Is(err, kpu.ErrInvalidCode) {
+		t.Errorf("GetVotesByTPS of a regency: error = %v, want %v", err, kpu.ErrInvalidCode)
+	}
+}
+
+func TestSirekapRetryArchive(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) < 3 {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`[{"nama":"ACEH","id":1,"kode":"11","tingkat":1}]`))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "sirekap.jsonl.gz")
+	archive, err := kpu.CreateArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sirekap := kpu.NewSirekap(srv.Client(),
+		kpu.WithHost(srv.URL),
+		kpu.WithRateLimit(kpu.RateLimit{}),
+		kpu.WithRetry(kpu.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}),
+		kpu.WithArchive(archive),
+	)
+
+	var provinces kpu.Locations
+	err = sirekap.FetchLocations(context.Background(), &provinces, kpu.Nationwide)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(provinces) != 1 || provinces[0].Code != kpu.Aceh {
+		t.Errorf("provinces = %+v", provinces)
+	}
+
+	err = archive.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the failed attempts are archived as well
+	var statuses []int
+	err = kpu.ReadArchive(path, func(record kpu.ArchiveRecord) error {
+		statuses = append(statuses, record.StatusCode)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 3 || statuses[0] != http.StatusServiceUnavailable || statuses[2] != http.StatusOK {
+		t.Errorf("archived statuses = %v, want 503, 503, 200", statuses)
+	}
+}
@@ -0,0 +1,25 @@
+package kpu
+
+import "context"
+
+// Source is the set of Sirekap endpoints consumed by the controller and presenters.
+// *Sirekap is the live implementation; replay, caching or fake sources only need to satisfy this.
+type Source interface {
+	FetchLocations(ctx context.Context, dest *Locations, parent Code) error
+	GetVotesByTPS(ctx context.Context, tpsCode Code) (ResponseDataTPS, error)
+	GetVotesPresidentialNationwide(ctx context.Context) (ResponseDataPresidentialNationwide, error)
+	GetVotesPresidentialByRegion(ctx context.Context, code Code) (ResponseDataPresidentialRegion, error)
+	GetVotesLegislativeNationwide(ctx context.Context) (ResponseDataLegislativeNationwide, error)
+	GetVotesLegislativeByRegion(ctx context.Context, code Code) (ResponseDataLegislativeRegion, error)
+	GetVotesLegislativeByTPS(ctx context.Context, tpsCode Code) (ResponseDataLegislativeTPS, error)
+	GetVotesDPDByRegion(ctx context.Context, code Code) (ResponseDataDPDRegion, error)
+	GetCandidatesDPD(ctx context.Context, provinceCode Code) (map[string]CandidateDPD, error)
+	GetDapilsDPR(ctx context.Context) ([]Dapil, error)
+	GetCandidatesDPR(ctx context.Context, dapilCode string) (map[string]map[string]CandidateDPR, error)
+	GetVotesLegislativeByDapil(ctx context.Context, dapilCode string) (ResponseDataLegislativeDapil, error)
+	GetCandidatesPresidential(ctx context.Context) (map[string]CandidatePair, error)
+	GetParties(ctx context.Context) (PartyRegistry, error)
+	GetVotesRegion(ctx context.Context, election Election, code Code) (ResponseDataRegion, error)
+}
+
+var _ Source = (*Sirekap)(nil)
@@ -0,0 +1,68 @@
+package kpu
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// TableRow is one row of the table of a region response: the votes of one child region,
+// keyed by candidate or party ID, next to its status fields.
+type TableRow struct {
+	Votes          map[string]int64
+	PSU            *PSU
+	Persen         *float64
+	StatusProgress *bool
+}
+
+func (t *TableRow) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+
+	*t = TableRow{Votes: make(map[string]int64, len(raw))}
+	for key, value := range raw {
+		switch key {
+		case "psu":
+			err = json.Unmarshal(value, &t.PSU)
+		case "persen":
+			err = json.Unmarshal(value, &t.Persen)
+		case "status_progress":
+			err = json.Unmarshal(value, &t.StatusProgress)
+		default:
+			// a null vote is a region not counted yet, kept as 0 so every row has the same keys
+			var votes *int64
+			err = json.Unmarshal(value, &votes)
+			t.Votes[key] = 0
+			if votes != nil {
+				t.Votes[key] = *votes
+			}
+		}
+
+		if err != nil {
+			return fmt.Errorf("error on decode table field %q: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+func (t TableRow) MarshalJSON() ([]byte, error) {
+	raw := make(map[string]any, len(t.Votes)+3)
+	for key, votes := range t.Votes {
+		raw[key] = votes
+	}
+
+	if t.PSU != nil {
+		raw["psu"] = t.PSU
+	}
+	if t.Persen != nil {
+		raw["persen"] = t.Persen
+	}
+	if t.StatusProgress != nil {
+		raw["status_progress"] = t.StatusProgress
+	}
+
+	return json.Marshal(raw)
+}
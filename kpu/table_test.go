@@ -0,0 +1,53 @@
+package kpu
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestTableRowNull(t *testing.T) {
+	var row TableRow
+	err := json.Unmarshal([]byte(`{"100025":12,"100026":null,"psu":"Reguler","persen":null,"status_progress":true}`), &row)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a null vote is kept as 0, so that every row has the same keys
+	want := map[string]int64{"100025": 12, "100026": 0}
+	if !reflect.DeepEqual(row.Votes, want) {
+		t.Errorf("Votes = %v, want %v", row.Votes, want)
+	}
+
+	if row.PSU == nil || *row.PSU != Reguler {
+		t.Errorf("PSU = %v, want %s", row.PSU, Reguler)
+	}
+	if row.Persen != nil {
+		t.Errorf("Persen = %v, want nil", *row.Persen)
+	}
+	if row.StatusProgress == nil || !*row.StatusProgress {
+		t.Errorf("StatusProgress = %v, want true", row.StatusProgress)
+	}
+
+	data, err := json.Marshal(row)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var back TableRow
+	err = json.Unmarshal(data, &back)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, row) {
+		t.Errorf("round trip = %+v, want %+v", back, row)
+	}
+}
+
+func TestTableRowMalformed(t *testing.T) {
+	var row TableRow
+	err := json.Unmarshal([]byte(`{"100025":"12"}`), &row)
+	if err == nil {
+		t.Error("Unmarshal of a string vote: want an error")
+	}
+}
@@ -0,0 +1,74 @@
+package kpu
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// TsLayout is the layout of the ts field of every Sirekap response, e.g. "2024-02-25 16:00:16".
+const TsLayout = "2006-01-02 15:04:05"
+
+// WIB is the Asia/Jakarta time zone Sirekap timestamps are written in. Indonesia has no
+// daylight saving time, so a fixed zone is exact and does not depend on the tzdata of the host.
+var WIB = time.FixedZone("WIB", 7*60*60)
+
+// Time is a Sirekap timestamp. It decodes the zoneless upstream format as WIB and encodes
+// as RFC 3339 with the +07:00 offset, so it is never mistaken for UTC once it leaves this package.
+type Time struct {
+	time.Time
+}
+
+func (t *Time) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		t.Time = time.Time{}
+		return nil
+	}
+
+	var raw string
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return fmt.Errorf("error on decode ts: %w", err)
+	}
+
+	return t.parse(raw)
+}
+
+func (t Time) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+
+	return json.Marshal(t.In(WIB).Format(time.RFC3339))
+}
+
+// String formats t the way Sirekap does, in WIB.
+func (t Time) String() string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.In(WIB).Format(TsLayout)
+}
+
+func (t *Time) parse(raw string) error {
+	if raw == "" {
+		t.Time = time.Time{}
+		return nil
+	}
+
+	parsed, err := time.ParseInLocation(TsLayout, raw, WIB)
+	if err != nil {
+		// accept our own encoding back, e.g. from votes_nationwide.json
+		var errRFC3339 error
+		parsed, errRFC3339 = time.Parse(time.RFC3339, raw)
+		if errRFC3339 != nil {
+			return fmt.Errorf("error on parse ts %q: %w", raw, err)
+		}
+	}
+
+	t.Time = parsed
+
+	return nil
+}
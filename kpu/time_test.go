@@ -0,0 +1,62 @@
+package kpu
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestTimeJSON(t *testing.T) {
+	var ts Time
+	err := json.Unmarshal([]byte(`"2024-02-25 16:00:16"`), &ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the upstream format has no zone, it is WIB
+	want := time.Date(2024, 2, 25, 9, 0, 16, 0, time.UTC)
+	if !ts.Equal(want) {
+		t.Errorf("ts = %s, want %s", ts.UTC(), want)
+	}
+	if got := ts.String(); got != "2024-02-25 16:00:16" {
+		t.Errorf("String() = %q", got)
+	}
+
+	data, err := json.Marshal(ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `"2024-02-25T16:00:16+07:00"` {
+		t.Errorf("MarshalJSON() = %s", data)
+	}
+
+	// our own encoding decodes back to the same instant
+	var back Time
+	err = json.Unmarshal(data, &back)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !back.Equal(ts.Time) {
+		t.Errorf("round trip = %s, want %s", back, ts)
+	}
+}
+
+func TestTimeJSONEmpty(t *testing.T) {
+	for _, in := range []string{`null`, `""`} {
+		ts := Time{time.Now()}
+		err := json.Unmarshal([]byte(in), &ts)
+		if err != nil || !ts.IsZero() {
+			t.Errorf("Unmarshal(%s) = %s, %v, want zero", in, ts, err)
+		}
+	}
+
+	data, err := json.Marshal(Time{})
+	if err != nil || string(data) != "null" {
+		t.Errorf("MarshalJSON() of zero = %s, %v", data, err)
+	}
+
+	var ts Time
+	if err := json.Unmarshal([]byte(`"25/02/2024"`), &ts); err == nil {
+		t.Error("Unmarshal of a malformed ts: want an error")
+	}
+}
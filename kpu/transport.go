@@ -0,0 +1,87 @@
+package kpu
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// TransportConfig configures the connection to Sirekap. The zero value verifies the certificates
+// against the system roots and has no timeout, use DefaultTransportConfig.
+type TransportConfig struct {
+	// InsecureSkipVerify disables the certificate verification. Anyone on the path can then serve
+	// fabricated votes, only use it to work around a broken certificate chain.
+	InsecureSkipVerify bool
+	// CAFile is a PEM bundle trusted on top of the system roots, e.g. the certificate of a mirror.
+	CAFile string
+	// Proxy is the URL of the HTTP(S) proxy. Empty uses HTTPS_PROXY / NO_PROXY from the environment.
+	Proxy string
+
+	DialTimeout           time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	IdleConnTimeout       time.Duration
+}
+
+var DefaultTransportConfig = TransportConfig{
+	DialTimeout:           30 * time.Second,
+	TLSHandshakeTimeout:   10 * time.Second,
+	ResponseHeaderTimeout: 30 * time.Second,
+	IdleConnTimeout:       90 * time.Second,
+}
+
+// NewTransport returns the transport every client of Sirekap is built on. Wrap it for logging
+// or conditional requests, see ConditionalTransport.
+func NewTransport(config TransportConfig) (*http.Transport, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: config.InsecureSkipVerify, //nolint:gosec // explicit opt-out
+	}
+
+	if config.CAFile != "" {
+		pem, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error on read CA bundle: %w", err)
+		}
+
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("error on read CA bundle: no PEM certificate in %s", config.CAFile)
+		}
+
+		tlsConfig.RootCAs = roots
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if config.Proxy != "" {
+		proxyURL, err := url.Parse(config.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("error on parse proxy URL: %w", err)
+		}
+
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	return &http.Transport{
+		Proxy: proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   config.DialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   config.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: config.ResponseHeaderTimeout,
+		IdleConnTimeout:       config.IdleConnTimeout,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConnsPerHost:   DefaultRateLimit.Burst,
+	}, nil
+}
@@ -0,0 +1,102 @@
+package kpu
+
+import (
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// transportGet fetches rawURL with a transport of config.
+func transportGet(t *testing.T, config TransportConfig, rawURL string) (string, error) {
+	t.Helper()
+
+	transport, err := NewTransport(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transport.CloseIdleConnections()
+
+	resp, err := (&http.Client{Transport: transport}).Get(rawURL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+
+	return string(body), err
+}
+
+func TestNewTransportCAFile(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the certificate of the test server is self-signed, only the bundle makes it trusted
+	if _, err := transportGet(t, DefaultTransportConfig, srv.URL); err == nil {
+		t.Error("self-signed certificate trusted without the CA bundle")
+	}
+
+	config := DefaultTransportConfig
+	config.CAFile = caFile
+	if body, err := transportGet(t, config, srv.URL); err != nil || body != "ok" {
+		t.Errorf("with the CA bundle: %q, %v", body, err)
+	}
+
+	config = DefaultTransportConfig
+	config.InsecureSkipVerify = true
+	if body, err := transportGet(t, config, srv.URL); err != nil || body != "ok" {
+		t.Errorf("without verification: %q, %v", body, err)
+	}
+
+	notPEM := filepath.Join(dir, "not.pem")
+	err = os.WriteFile(notPEM, []byte("not a certificate"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{notPEM, filepath.Join(dir, "missing.pem")} {
+		config := DefaultTransportConfig
+		config.CAFile = name
+		if _, err := NewTransport(config); err == nil {
+			t.Errorf("no error on the CA bundle %s", filepath.Base(name))
+		}
+	}
+}
+
+func TestNewTransportProxy(t *testing.T) {
+	proxied := make(chan string, 1)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied <- r.URL.String()
+		io.WriteString(w, "proxied")
+	}))
+	defer proxy.Close()
+
+	config := DefaultTransportConfig
+	config.Proxy = proxy.URL
+
+	body, err := transportGet(t, config, "http://sirekap-obj-data.kpu.go.id/pemilu/hhcw/ppwp.json")
+	if err != nil || body != "proxied" {
+		t.Fatalf("through the proxy: %q, %v", body, err)
+	}
+	if got := <-proxied; got != "http://sirekap-obj-data.kpu.go.id/pemilu/hhcw/ppwp.json" {
+		t.Errorf("proxy got %s, want the absolute URL of the request", got)
+	}
+
+	config.Proxy = "http://proxy:port"
+	if _, err := NewTransport(config); err == nil {
+		t.Error("no error on an invalid proxy URL")
+	}
+}
@@ -1,7 +1,8 @@
 package main
 
 import (
-	"crypto/tls"
+	"encoding/json"
+	"flag"
 	"log"
 	"net/http"
 
@@ -14,11 +15,16 @@ type loggingTransport struct {
 }
 
 func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	log.Printf("Request: %s %s\n", req.Method, req.URL.String())
+	if attempt := kpu.Attempt(req.Context()); attempt > 1 {
+		log.Printf("Request: %s %s (retry #%d)\n", req.Method, req.URL.String(), attempt-1)
+	} else {
+		log.Printf("Request: %s %s\n", req.Method, req.URL.String())
+	}
 
 	// Execute the request
 	resp, err := t.Transport.RoundTrip(req)
 	if err != nil {
+		log.Printf("Response error: %s\n", err)
 		return nil, err
 	}
 
@@ -27,8 +33,25 @@ func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error)
 }
 
 func main() {
-	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	rateLimit := flag.Float64("rateLimit", kpu.DefaultRateLimit.PerSecond, "max KPU requests per second, 0 to disable")
+	rateBurst := flag.Int("rateBurst", kpu.DefaultRateLimit.Burst, "max KPU requests at once before rateLimit applies")
+	strict := flag.Bool("strict", false, "compare every KPU response with the expected schema, the drift report is served on /drift-report")
+	transportConfig := kpu.DefaultTransportConfig
+	flag.BoolVar(&transportConfig.InsecureSkipVerify, "insecureSkipVerify", false, "do not verify the TLS certificate of KPU, only to work around a broken chain")
+	flag.StringVar(&transportConfig.CAFile, "caFile", "", "PEM bundle of extra trusted CA certificates")
+	flag.StringVar(&transportConfig.Proxy, "proxy", "", "HTTP(S) proxy URL, empty to use HTTPS_PROXY from the environment")
+	flag.DurationVar(&transportConfig.DialTimeout, "dialTimeout", transportConfig.DialTimeout, "timeout of connecting to KPU")
+	flag.DurationVar(&transportConfig.TLSHandshakeTimeout, "tlsHandshakeTimeout", transportConfig.TLSHandshakeTimeout, "timeout of the TLS handshake with KPU")
+	flag.DurationVar(&transportConfig.ResponseHeaderTimeout, "responseHeaderTimeout", transportConfig.ResponseHeaderTimeout, "timeout of waiting for the response headers of KPU")
+	flag.Parse()
+
+	if transportConfig.InsecureSkipVerify {
+		log.Println("WARNING TLS verification of KPU is disabled, responses can be tampered with")
+	}
+
+	transport, err := kpu.NewTransport(transportConfig)
+	if err != nil {
+		log.Fatal(err)
 	}
 
 	client := &http.Client{
@@ -37,10 +60,28 @@ func main() {
 		},
 	}
 
-	presenter := presenter.NewPresenterHTTP(kpu.NewSirekap(client))
+	opts := []kpu.Option{kpu.WithRateLimit(kpu.RateLimit{PerSecond: *rateLimit, Burst: *rateBurst})}
+
+	driftReport := kpu.NewDriftReport()
+	if *strict {
+		opts = append(opts, kpu.WithDriftReport(driftReport))
+	}
+
+	sirekap := kpu.NewSirekap(client, opts...)
+	presenter := presenter.NewPresenterHTTP(sirekap)
 
 	http.HandleFunc("/fetch-votes", presenter.GetVotes)
+	http.HandleFunc("/fetch-votes-region", presenter.GetVotesByRegion)
+	http.HandleFunc("/fetch-votes-dpr", presenter.GetVotesLegislative)
+	http.HandleFunc("/fetch-votes-dpr-region", presenter.GetVotesLegislativeByRegion)
+	http.HandleFunc("/fetch-caleg-dpr-dapil", presenter.GetCandidatesDPRByDapil)
 	http.HandleFunc("/fetch-locations", presenter.GetLocations)
+	http.HandleFunc("/fetch-locations-overseas", presenter.GetLocationsOverseas)
+
+	http.HandleFunc("/drift-report", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(driftReport.Drifts())
+	})
 
 	log.Println("Server started on :8080")
 	log.Fatal(http.ListenAndServe(":8080", nil))
@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pararang/pemilu2024/kpu"
+	"github.com/pararang/pemilu2024/kpu/kputest"
+)
+
+// inTempDir runs the test in an empty working directory, the commands write their files relative to it.
+func inTempDir(t *testing.T) string {
+	t.Helper()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	err = os.Chdir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	return dir
+}
+
+func readCSV(t *testing.T, name string) [][]string {
+	t.Helper()
+
+	file, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("error on read %s: %v", name, err)
+	}
+
+	return records
+}
+
+func TestRunFetchLocations(t *testing.T) {
+	inTempDir(t)
+
+	srv := kputest.NewSyntheticServer(kputest.DefaultTree)
+	defer srv.Close()
+
+	fileType, staticFileName, maxLoop, locationDepth = "csv", true, 0, kpu.LevelTPS
+	t.Cleanup(func() { fileType, staticFileName, locationDepth = "", false, kpu.LevelVillage })
+
+	err := runFetchLocations(context.Background(), srv.Sirekap())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := readCSV(t, "indonesia_location.csv")
+
+	tree := kputest.DefaultTree
+	want, n := 0, 1
+	for _, children := range []int{tree.Provinces, tree.Cities, tree.Districts, tree.Villages, tree.TPS} {
+		n *= children
+		want += n
+	}
+	if len(records) != want+1 {
+		t.Fatalf("%d rows, want a header and %d locations", len(records), want)
+	}
+
+	// every location comes after its parent
+	ids := map[string]kpu.Code{"0": kpu.Nationwide}
+	for _, record := range records[1:] {
+		code := kpu.Code(record[1])
+		parent, ok := ids[record[4]]
+		if !ok || code.Parent() != parent || strconv.Itoa(code.Level()) != record[3] {
+			t.Fatalf("location %v: parent %q", record, parent)
+		}
+		ids[record[0]] = code
+	}
+
+	// Luar Negeri, its country, its PPLN, the three method groups and their polling places
+	overseas := readCSV(t, "luar_negeri_location.csv")
+	if want := 1 + tree.Countries*(2+3*(1+tree.TPS)); len(overseas) != want+1 {
+		t.Fatalf("%d overseas rows, want a header and %d locations", len(overseas), want)
+	}
+	for _, record := range overseas[1:] {
+		level, _ := strconv.Atoi(record[3])
+		if method := kpu.VotingMethodOf(record[2]); level >= kpu.LevelVillage && (method == "" || string(method) != record[5]) {
+			t.Errorf("overseas location %v, want the method of its name", record)
+		}
+	}
+}
+
+func TestRunFetchVotes(t *testing.T) {
+	dir := inTempDir(t)
+
+	srv := kputest.NewSyntheticServer(kputest.DefaultTree)
+	defer srv.Close()
+
+	votesDir = dir
+	t.Cleanup(func() { votesDir = "output/votes" })
+
+	sirekap := srv.Sirekap()
+	notModified := func(string) bool { return false }
+
+	// a second run appends a row under the header written by the first one
+	for i := 0; i < 2; i++ {
+		if err := runFetchVotes(context.Background(), sirekap, notModified); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	votes, err := sirekap.GetVotesPresidentialNationwide(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var provinces kpu.Locations
+	err = sirekap.FetchLocations(context.Background(), &provinces, kpu.Nationwide)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pairs, err := sirekap.GetCandidatesPresidential(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	candidates := candidateColumns(pairs)
+	var checked int
+	for _, prov := range provinces {
+		row, ok := votes.Table[string(prov.Code)]
+		if !ok {
+			continue
+		}
+		checked++
+
+		records := readCSV(t, filepath.Join(dir, "votes_0_"+strings.ReplaceAll(strings.ToLower(prov.Name), " ", "_")+".csv"))
+		if len(records) != 3 {
+			t.Fatalf("%s: %d rows, want a header and 2 runs", prov.Name, len(records))
+		}
+
+		// ts, the candidates by ballot number, then created_at
+		for _, record := range records[1:] {
+			if len(record) != len(candidates)+2 || record[0] != votes.Ts.String() {
+				t.Fatalf("%s: row %v", prov.Name, record)
+			}
+			for i, id := range candidates {
+				if record[i+1] != strconv.FormatInt(row.Votes[id], 10) {
+					t.Errorf("%s: column %s = %s, want %d", prov.Name, records[0][i+1], record[i+1], row.Votes[id])
+				}
+			}
+		}
+	}
+
+	if checked == 0 {
+		t.Fatal("no province in the presidential votes")
+	}
+
+	for _, name := range []string{"votes_dpr_0_*.csv", "votes_nationwide.json"} {
+		names, err := filepath.Glob(filepath.Join(dir, name))
+		if err != nil || len(names) == 0 {
+			t.Errorf("%s not written: %v", name, err)
+		}
+	}
+}
+
+func TestRunFetchVotesCancelled(t *testing.T) {
+	dir := inTempDir(t)
+
+	srv := kputest.NewSyntheticServer(kputest.DefaultTree)
+	defer srv.Close()
+
+	votesDir = dir
+	t.Cleanup(func() { votesDir = "output/votes" })
+
+	// an interrupted run returns, so that Execute still closes the archive and saves the reports
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := runFetchVotes(ctx, srv.Sirekap(), func(string) bool { return false })
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("runFetchVotes = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestCheckHeader(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "votes.csv")
+	err := os.WriteFile(name, []byte("ts,1,2,created_at\n2024-02-25 16:00:16,1,2,now\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkHeader(name, 4); err != nil {
+		t.Errorf("checkHeader with the same columns: %v", err)
+	}
+	if err := checkHeader(name, 5); err == nil {
+		t.Error("checkHeader with a new column: want an error")
+	}
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/pararang/pemilu2024/controller"
+	"github.com/pararang/pemilu2024/kpu"
+	"github.com/spf13/cobra"
+)
+
+var c1Dir string
+var c1Concurrency int
+
+// fetchC1Cmd represents the fetchC1 command
+var fetchC1Cmd = &cobra.Command{
+	Use:   "fetchC1 code...",
+	Short: "download the C1 scans of TPS",
+	Long:  "download the C1 scans of every TPS under each code, a TPS or any region above it, revalidating the scans already in the mirror",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fmt.Println("fetchC1 called")
+
+		codes := make([]kpu.Code, 0, len(args))
+		for _, arg := range args {
+			code, err := kpu.ParseCode(arg)
+			if err != nil {
+				return err
+			}
+			codes = append(codes, code)
+		}
+
+		sirekap := newSirekap()
+		defer logLimiterStats(sirekap)
+
+		// the scans are on another host than the JSON, with a rate limit of their own
+		files := newFileSirekap()
+		defer logLimiterStats(files)
+
+		return runFetchC1(cmd.Context(), sirekap, files, codes)
+	},
+}
+
+// runFetchC1 downloads the scans of every TPS under codes, reading the TPS from sirekap and the scans with files.
+func runFetchC1(ctx context.Context, sirekap kpu.Source, files *kpu.Sirekap, codes []kpu.Code) error {
+	controller := controller.NewController(sirekap)
+
+	var tps []kpu.Code
+	for _, code := range codes {
+		tpsCodes, err := controller.GetTPSCodes(ctx, code)
+		if err != nil {
+			return err
+		}
+		tps = append(tps, tpsCodes...)
+	}
+
+	downloader, err := kpu.NewC1Downloader(sirekap, files, c1Dir, c1Concurrency)
+	if err != nil {
+		return err
+	}
+
+	stats, err := downloader.Download(ctx, tps)
+
+	// the manifest keeps what was downloaded before a failure, the next run resumes from there
+	if errSave := downloader.Save(); errSave != nil {
+		log.Println(errSave)
+	}
+
+	log.Printf("C1 of %d TPS: %d downloaded, %d unchanged, %d missing\n", len(tps), stats.Downloaded, stats.Unchanged, stats.Missing)
+
+	return err
+}
+
+func init() {
+	rootCmd.AddCommand(fetchC1Cmd)
+
+	fetchC1Cmd.Flags().StringVar(&c1Dir, "dir", "output/c1", "directory of the C1 mirror")
+	fetchC1Cmd.Flags().IntVar(&c1Concurrency, "concurrency", 4, "TPS downloaded at once")
+}
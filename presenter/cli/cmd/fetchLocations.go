@@ -2,11 +2,14 @@
 package cmd
 
 import (
+	"context"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"strconv"
 	"time"
 
@@ -15,12 +18,22 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// locationsArchiveDir receives the raw responses of fetchLocations unless --archiveDir says otherwise,
+// apart from the votes ones so that replay only ever replays the runs of one command.
+const locationsArchiveDir = "output/raw/locations"
+
+// locationDepth is the deepest level of the crawled tree, the TPS level alone is over 800k locations.
+var locationDepth int
+
+// locationsDir receives the location files, the working directory when empty.
+var locationsDir string
+
 // fetchLocationsCmd represents the fetchLocations command
 var fetchLocationsCmd = &cobra.Command{
 	Use:   "fetchLocations",
 	Short: "Fetc location and save it to the persistent storage",
 	Long:  "Fetc location and save it to the persistent storage",
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		fmt.Printf("fetchLocations called, fileType=%s\n", fileType)
 
 		start := time.Now()
@@ -28,99 +41,208 @@ var fetchLocationsCmd = &cobra.Command{
 			log.Printf("done after %s", time.Since(start).String())
 		}(start)
 
-		controller := controller.NewController(kpu.NewSirekap(stdHttpClient))
-		locations, err := controller.GetLocations(maxLoop)
-		if err != nil {
-			log.Fatal(err)
+		if !cmd.Flags().Changed("archiveDir") {
+			archiveDir = locationsArchiveDir
 		}
 
-		fileName := "indonesia_location"
-		if !staticFileName {
-			fileName = fmt.Sprintf("%s_%s", fileName, time.Now().Format("20060102-150405"))
-		}
+		sirekapClient := newSirekap()
+		defer logLimiterStats(sirekapClient)
+
+		return runFetchLocations(cmd.Context(), sirekapClient)
+	},
+}
 
-		fileName = fmt.Sprintf("%s.%s", fileName, fileType)
+// runFetchLocations crawls the location tree and writes it as fileType.
+func runFetchLocations(ctx context.Context, sirekapClient kpu.Source) error {
+	controller := controller.NewController(sirekapClient)
+	locations, err := controller.GetLocations(ctx, maxLoop, locationDepth)
+	if err != nil {
+		return err
+	}
 
-		if fileType == "json" {
-			jsonData, err := json.Marshal(locations)
-			if err != nil {
-				log.Fatal(err)
-			}
+	fileName := "indonesia_location"
+	if !staticFileName {
+		fileName = fmt.Sprintf("%s_%s", fileName, timeProcessed.Format("20060102-150405"))
+	}
 
-			err = os.WriteFile(fileName, jsonData, 0644)
-			if err != nil {
-				log.Fatal(err)
-			}
+	fileName = filepath.Join(locationsDir, fmt.Sprintf("%s.%s", fileName, fileType))
+
+	if fileType == "json" {
+		jsonData, err := json.Marshal(locations)
+		if err != nil {
+			return err
 		}
 
-		if fileType == "csv" {
-			file, err := os.Create(fileName)
-			if err != nil {
-				log.Fatal(err)
-			}
-			defer file.Close()
+		err = os.WriteFile(fileName, jsonData, 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	if fileType == "csv" {
+		file, err := os.Create(fileName)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
 
-			writer := csv.NewWriter(file)
-			defer writer.Flush()
+		writer := csv.NewWriter(file)
+		defer writer.Flush()
 
-			if err := writer.Write([]string{"ID", "Code", "Nama", "Level", "ParentID"}); err != nil {
-				log.Fatal(err)
+		if err := writer.Write([]string{"ID", "Code", "Nama", "Level", "ParentID"}); err != nil {
+			return err
+		}
+
+		for iProv := 0; iProv < len(locations); iProv++ {
+			if err := writer.Write([]string{
+				strconv.Itoa(int(locations[iProv].ID)),
+				string(locations[iProv].Code),
+				locations[iProv].Name,
+				strconv.Itoa(int(locations[iProv].Level)),
+				"0",
+			}); err != nil {
+				return err
 			}
 
-			for iProv := 0; iProv < len(locations); iProv++ {
+			for iCity := 0; iCity < len(locations[iProv].Cities); iCity++ {
 				if err := writer.Write([]string{
+					strconv.Itoa(int(locations[iProv].Cities[iCity].ID)),
+					string(locations[iProv].Cities[iCity].Code),
+					locations[iProv].Cities[iCity].Name,
+					strconv.Itoa(int(locations[iProv].Cities[iCity].Level)),
 					strconv.Itoa(int(locations[iProv].ID)),
-					locations[iProv].Code,
-					locations[iProv].Name,
-					strconv.Itoa(int(locations[iProv].Level)),
-					"0",
 				}); err != nil {
-					log.Fatal(err)
+					return err
 				}
 
-				for iCity := 0; iCity < len(locations[iProv].Cities); iCity++ {
+				for iDist := 0; iDist < len(locations[iProv].Cities[iCity].Districts); iDist++ {
 					if err := writer.Write([]string{
+						strconv.Itoa(int(locations[iProv].Cities[iCity].Districts[iDist].ID)),
+						string(locations[iProv].Cities[iCity].Districts[iDist].Code),
+						locations[iProv].Cities[iCity].Districts[iDist].Name,
+						strconv.Itoa(int(locations[iProv].Cities[iCity].Districts[iDist].Level)),
 						strconv.Itoa(int(locations[iProv].Cities[iCity].ID)),
-						locations[iProv].Cities[iCity].Code,
-						locations[iProv].Cities[iCity].Name,
-						strconv.Itoa(int(locations[iProv].Cities[iCity].Level)),
-						strconv.Itoa(int(locations[iProv].ID)),
 					}); err != nil {
-						log.Fatal(err)
+						return err
 					}
 
-					for iDist := 0; iDist < len(locations[iProv].Cities[iCity].Districts); iDist++ {
+					for iSubd := 0; iSubd < len(locations[iProv].Cities[iCity].Districts[iDist].Subdistrict); iSubd++ {
+						village := locations[iProv].Cities[iCity].Districts[iDist].Subdistrict[iSubd]
 						if err := writer.Write([]string{
+							strconv.Itoa(int(village.ID)),
+							string(village.Code),
+							village.Name,
+							strconv.Itoa(int(village.Level)),
 							strconv.Itoa(int(locations[iProv].Cities[iCity].Districts[iDist].ID)),
-							locations[iProv].Cities[iCity].Districts[iDist].Code,
-							locations[iProv].Cities[iCity].Districts[iDist].Name,
-							strconv.Itoa(int(locations[iProv].Cities[iCity].Districts[iDist].Level)),
-							strconv.Itoa(int(locations[iProv].Cities[iCity].ID)),
 						}); err != nil {
-							log.Fatal(err)
+							return err
 						}
-						
-						for iSubd := 0; iSubd < len(locations[iProv].Cities[iCity].Districts[iDist].Subdistrict); iSubd++ {
+
+						for iTPS := 0; iTPS < len(village.TPS); iTPS++ {
 							if err := writer.Write([]string{
-								strconv.Itoa(int(locations[iProv].Cities[iCity].Districts[iDist].Subdistrict[iSubd].ID)),
-								locations[iProv].Cities[iCity].Districts[iDist].Subdistrict[iSubd].Code,
-								locations[iProv].Cities[iCity].Districts[iDist].Subdistrict[iSubd].Name,
-								strconv.Itoa(int(locations[iProv].Cities[iCity].Districts[iDist].Subdistrict[iSubd].Level)),
-								strconv.Itoa(int(locations[iProv].Cities[iCity].Districts[iDist].ID)),
+								strconv.Itoa(int(village.TPS[iTPS].ID)),
+								string(village.TPS[iTPS].Code),
+								village.TPS[iTPS].Name,
+								strconv.Itoa(int(village.TPS[iTPS].Level)),
+								strconv.Itoa(int(village.ID)),
 							}); err != nil {
-								log.Fatal(err)
+								return err
 							}
 						}
 					}
 				}
 			}
 		}
-	},
+	}
+
+	return runFetchLocationsOverseas(ctx, controller)
+}
+
+// runFetchLocationsOverseas crawls the Luar Negeri branch and writes it as fileType, next to the provinces.
+// Its CSV has the voting method of the levels 4 and 5 as an extra column.
+func runFetchLocationsOverseas(ctx context.Context, controller *controller.Controller) error {
+	overseas, err := controller.GetLocationsOverseas(ctx, locationDepth)
+	if errors.Is(err, kpu.ErrNotFound) {
+		log.Printf("skip overseas locations: %v", err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	fileName := "luar_negeri_location"
+	if !staticFileName {
+		fileName = fmt.Sprintf("%s_%s", fileName, timeProcessed.Format("20060102-150405"))
+	}
+
+	fileName = filepath.Join(locationsDir, fmt.Sprintf("%s.%s", fileName, fileType))
+
+	if fileType == "json" {
+		jsonData, err := json.Marshal(overseas)
+		if err != nil {
+			return err
+		}
+
+		err = os.WriteFile(fileName, jsonData, 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	if fileType == "csv" {
+		file, err := os.Create(fileName)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		writer := csv.NewWriter(file)
+		defer writer.Flush()
+
+		// the writer keeps its first error, checked once flushed
+		write := func(location kpu.Location, parentID int64, method kpu.VotingMethod) {
+			writer.Write([]string{
+				strconv.Itoa(int(location.ID)),
+				string(location.Code),
+				location.Name,
+				strconv.Itoa(int(location.Level)),
+				strconv.Itoa(int(parentID)),
+				string(method),
+			})
+		}
+
+		if err := writer.Write([]string{"ID", "Code", "Nama", "Level", "ParentID", "Method"}); err != nil {
+			return err
+		}
+
+		write(overseas.Location, 0, "")
+		for _, country := range overseas.Countries {
+			write(country.Location, overseas.ID, "")
+			for _, ppln := range country.PPLN {
+				write(ppln.Location, country.ID, "")
+				for _, method := range ppln.Methods {
+					write(method.Location, ppln.ID, method.Method)
+					for _, place := range method.PollingPlaces {
+						write(place.Location, method.ID, place.Method)
+					}
+				}
+			}
+		}
+
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func init() {
 	rootCmd.AddCommand(fetchLocationsCmd) //nolint:typecheck
 
+	fetchLocationsCmd.Flags().IntVar(&locationDepth, "depth", kpu.LevelVillage, "deepest level to fetch, 1 province to 5 TPS")
+
 	// Here you will define your flags and configuration settings.
 
 	// Cobra supports Persistent Flags which will work for this command
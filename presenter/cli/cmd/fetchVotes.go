@@ -4,11 +4,16 @@ Copyright © 2024 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"context"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -18,184 +23,291 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// votesArchiveDir receives the raw responses of fetchVotes unless --archiveDir says otherwise,
+// so the output history can always be rebuilt with the replay command.
+const votesArchiveDir = "output/raw"
+
+var votesDir = "output/votes"
+
 // fetchVotesCmd represents the fetchVotes command
 var fetchVotesCmd = &cobra.Command{
 	Use:   "fetchVotes",
 	Short: "fetch votes",
 	Long:  "fetch votes from KPU and save it to the file(s)",
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		fmt.Println("fetchVotes called")
 
-		sirekapClient := kpu.NewSirekap(stdHttpClient)
-		controller := controller.NewController(sirekapClient)
-		votesPresident, err := controller.GetVotesNationwide()
-		if err != nil {
-			log.Fatal(err)
-		}
+		setupVotesRun(cmd, votesArchiveDir)
 
-		localData := struct {
-			LocalTimestamp string                                 `json:"local_timestamp"`
-			Raw            kpu.ResponseDataPresidentialNationwide `json:"raw_data"`
-		}{
-			LocalTimestamp: timeProcessed.Format(time.RFC3339),
-			Raw:            votesPresident,
-		}
+		sirekap := newSirekap()
+		defer logLimiterStats(sirekap)
 
-		jsonData, err := json.MarshalIndent(localData, "", "\t")
-		if err != nil {
-			log.Fatal(err)
-		}
+		return runFetchVotes(cmd.Context(), sirekap, conditionalTransport.NotModified)
+	},
+}
 
-		err = os.WriteFile("output/votes/votes_nationwide.json", jsonData, 0644)
-		if err != nil {
-			log.Fatal(err)
-		}
+// setupVotesRun defaults the flags of the commands appending votes: their run is archived to dir,
+// and their votes bypass the disk cache. A cached response would append a stale row, which
+// the not modified check of fetchVotes can not tell apart from a new one.
+func setupVotesRun(cmd *cobra.Command, dir string) {
+	if !cmd.Flags().Changed("archiveDir") {
+		archiveDir = dir
+	}
 
-		var provinces kpu.Locations
-		err = sirekapClient.FetchLocations(&provinces, "0")
-		if err != nil {
-			log.Fatal(err)
-		}
+	if !cmd.Flags().Changed("cacheVoteTTL") {
+		diskCacheConfig.VoteTTL = 0
+	}
+}
 
-		var mapProvName = make(map[string]string, 0)
-		for _, prov := range provinces {
-			mapProvName[prov.Code] = prov.Name
-		}
+// runFetchVotes appends the current nationwide votes to the output files, skipping the elections
+// whose response notModified reports as unchanged since the previous run.
+func runFetchVotes(ctx context.Context, sirekap *kpu.Sirekap, notModified func(rawURL string) bool) error {
+	unchanged := func(paths ...string) bool {
+		source, err := sirekap.VotesURL(paths...)
+		return err == nil && notModified(source)
+	}
+
+	var sirekapClient kpu.Source = sirekap
+	controller := controller.NewController(sirekapClient)
+	votesPresident, err := controller.GetVotesNationwide(ctx)
+	if err != nil {
+		return err
+	}
 
-		saveVotesPresidential(mapProvName, votesPresident)
+	var provinces kpu.Locations
+	err = sirekapClient.FetchLocations(ctx, &provinces, kpu.Nationwide)
+	if err != nil {
+		return err
+	}
+
+	var mapProvName = make(map[string]string, 0)
+	for _, prov := range provinces {
+		mapProvName[string(prov.Code)] = prov.Name
+	}
 
-		votesLegislative, err := sirekapClient.GetVotesLegislativeNationwide()
+	if unchanged("ppwp") {
+		log.Println("presidential votes: no change since last run")
+	} else {
+		candidates, err := fetchCandidatesPresidential(ctx, sirekapClient)
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
-		saveVotesLegislative(mapProvName, votesLegislative)
-	},
+
+		savePresidential(mapProvName, votesPresident, candidates)
+	}
+
+	votesLegislative, err := sirekapClient.GetVotesLegislativeNationwide(ctx)
+	if errors.Is(err, kpu.ErrNotFound) || errors.Is(err, kpu.ErrNotArchived) {
+		log.Printf("skip legislative votes, not published yet: %v", err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if unchanged("pdpr") {
+		log.Println("legislative votes: no change since last run")
+		return nil
+	}
+
+	parties, err := fetchParties(ctx, sirekapClient)
+	if err != nil {
+		return err
+	}
+
+	saveVotesLegislative(mapProvName, votesLegislative, parties)
+
+	return nil
+}
+
+// fetchCandidatesPresidential returns the candidate pairs labelling the presidential columns, falling back
+// to kpu.DefaultCandidatesPresidential without master data, i.e. not published or replaying an archive that predates it.
+func fetchCandidatesPresidential(ctx context.Context, sirekapClient kpu.Source) (map[string]kpu.CandidatePair, error) {
+	candidates, err := sirekapClient.GetCandidatesPresidential(ctx)
+	if errors.Is(err, kpu.ErrNotFound) || errors.Is(err, kpu.ErrNotArchived) {
+		log.Printf("no presidential candidates, use the default candidates: %v", err)
+		return kpu.DefaultCandidatesPresidential, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return candidates, nil
+}
+
+// fetchParties returns the parties labelling the legislative columns, falling back to kpu.DefaultParties
+// when Sirekap has none.
+func fetchParties(ctx context.Context, sirekapClient kpu.Source) (kpu.PartyRegistry, error) {
+	parties, err := sirekapClient.GetParties(ctx)
+	if errors.Is(err, kpu.ErrNotFound) || errors.Is(err, kpu.ErrNotArchived) {
+		log.Printf("no parties, use the default registry: %v", err)
+		return kpu.DefaultParties, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return parties, nil
 }
 
 // TODO:return and handle error
-func saveVotesPresidential(mapProvName map[string]string, votes kpu.ResponseDataPresidentialNationwide) {
+func savePresidential(mapProvName map[string]string, votesPresident kpu.ResponseDataPresidentialNationwide, candidates map[string]kpu.CandidatePair) {
+	localData := struct {
+		LocalTimestamp string                                 `json:"local_timestamp"`
+		Raw            kpu.ResponseDataPresidentialNationwide `json:"raw_data"`
+	}{
+		LocalTimestamp: timeProcessed.Format(time.RFC3339),
+		Raw:            votesPresident,
+	}
+
+	jsonData, err := json.MarshalIndent(localData, "", "\t")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = os.WriteFile(filepath.Join(votesDir, "votes_nationwide.json"), jsonData, 0644)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	saveVotesPresidential(mapProvName, votesPresident, candidates)
+}
+
+// TODO:return and handle error
+func saveVotesPresidential(mapProvName map[string]string, votes kpu.ResponseDataPresidentialNationwide, candidates map[string]kpu.CandidatePair) {
+	ids := candidateColumns(candidates)
+
+	// created_at stays last, after the candidates, like in the files written so far
+	header := []string{"ts"}
+	for _, id := range ids {
+		header = append(header, controller.CandidateLabel(candidates, id))
+	}
+	header = append(header, "created_at")
+
 	for code, name := range mapProvName {
 		vote, ok := votes.Table[code]
 		if ok {
-			filename := strings.ReplaceAll(fmt.Sprintf("output/votes/votes_0_%s.csv", strings.ToLower(name)), " ", "_")
-			var osFile *os.File
-			_, err := os.Stat(filename)
-			var isCreate bool
-			if os.IsNotExist(err) {
-				// File doesn't exist, create it
-				osFile, err = os.Create(filename)
-				isCreate = true
-			} else {
-				// File exists, open it in append mode
-				osFile, err = os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0644)
+			row := []string{votes.Ts.String()} // WIB, created_at is UTC
+			for _, id := range ids {
+				row = append(row, strconv.FormatInt(vote.Votes[id], 10))
 			}
+			row = append(row, timeProcessed.Format(time.RFC3339))
 
-			if err != nil {
-				log.Fatal(err)
-			}
-			defer osFile.Close()
+			appendVotes(strings.ReplaceAll(fmt.Sprintf("votes_0_%s.csv", strings.ToLower(name)), " ", "_"), header, row)
+		}
+	}
+}
 
-			osWriter := csv.NewWriter(osFile)
-			defer osWriter.Flush()
+// candidateColumns orders the IDs of the candidate pairs on the ballot by ballot number, then by ID. The columns
+// come from the master data rather than a row, whose keys may be missing or null before a region is counted.
+func candidateColumns(candidates map[string]kpu.CandidatePair) []string {
+	ids := make([]string, 0, len(candidates))
+	for id := range candidates {
+		ids = append(ids, id)
+	}
 
-			if isCreate {
-				if err := osWriter.Write([]string{"ts", "amin", "pagi", "gama", "created_at"}); err != nil {
-					log.Fatal(err)
-				}
-			}
-			if err := osWriter.Write([]string{
-				votes.Ts,
-				fmt.Sprintf("%d", *vote.The100025),
-				fmt.Sprintf("%d", *vote.The100026),
-				fmt.Sprintf("%d", *vote.The100027),
-				timeProcessed.Format(time.RFC3339),
-			}); err != nil {
-				log.Fatal(err)
-			}
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := candidates[ids[i]].Number, candidates[ids[j]].Number
+		if a != b {
+			return a < b
 		}
-	}
+		return ids[i] < ids[j]
+	})
+
+	return ids
 }
 
 // TODO:return and handle error
-func saveVotesLegislative(mapProvName map[string]string, votes kpu.ResponseDataLegislativeNationwide) {
+func saveVotesLegislative(mapProvName map[string]string, votes kpu.ResponseDataLegislativeNationwide, parties kpu.PartyRegistry) {
+	// the national parties are on the DPR ballot of every province
+	ids := parties.Ballot(false)
+
+	header := []string{"ts", "created_at"}
+	for _, id := range ids {
+		header = append(header, parties.Label(id))
+	}
+
 	for code, name := range mapProvName {
 		vote, ok := votes.Table[code]
 		if ok {
-			filename := strings.ReplaceAll(fmt.Sprintf("output/votes/votes_dpr_0_%s.csv", strings.ToLower(name)), " ", "_")
-			var osFile *os.File
-			_, err := os.Stat(filename)
-			var isCreate bool
-			if os.IsNotExist(err) {
-				// File doesn't exist, create it
-				osFile, err = os.Create(filename)
-				isCreate = true
-			} else {
-				// File exists, open it in append mode
-				osFile, err = os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0644)
-			}
-
-			if err != nil {
-				log.Fatal(err)
-			}
-			defer osFile.Close()
-
-			osWriter := csv.NewWriter(osFile)
-			defer osWriter.Flush()
-
-			if isCreate {
-				if err := osWriter.Write([]string{
-					"ts",
-					"created_at",
-					"PKB",
-					"Gerindra",
-					"PDI-P",
-					"Golkar",
-					"Nasdem",
-					"Partai Buruh",
-					"Gelora",
-					"PKS",
-					"PKN",
-					"Hanura",
-					"Garuda",
-					"PAN",
-					"PBB",
-					"Demokrat",
-					"PSI",
-					"Perindo",
-					"PPP",
-					"Partai Ummat",
-				}); err != nil {
-					log.Fatal(err)
-				}
-			}
-			if err := osWriter.Write([]string{
-				votes.Ts,
+			row := []string{
+				votes.Ts.String(), // WIB, created_at is UTC
 				timeProcessed.Format(time.RFC3339),
-				strconv.Itoa(int(vote.The1)),
-				strconv.Itoa(int(vote.The2)),
-				strconv.Itoa(int(vote.The3)),
-				strconv.Itoa(int(vote.The4)),
-				strconv.Itoa(int(vote.The5)),
-				strconv.Itoa(int(vote.The6)),
-				strconv.Itoa(int(vote.The7)),
-				strconv.Itoa(int(vote.The8)),
-				strconv.Itoa(int(vote.The9)),
-				strconv.Itoa(int(vote.The10)),
-				strconv.Itoa(int(vote.The11)),
-				strconv.Itoa(int(vote.The12)),
-				strconv.Itoa(int(vote.The13)),
-				strconv.Itoa(int(vote.The14)),
-				strconv.Itoa(int(vote.The15)),
-				strconv.Itoa(int(vote.The16)),
-				strconv.Itoa(int(vote.The17)),
-				strconv.Itoa(int(vote.The24)),
-			}); err != nil {
-				log.Fatal(err)
 			}
+			for _, id := range ids {
+				row = append(row, strconv.FormatInt(vote.Votes[id], 10))
+			}
+
+			appendVotes(strings.ReplaceAll(fmt.Sprintf("votes_dpr_0_%s.csv", strings.ToLower(name)), " ", "_"), header, row)
 		}
 	}
 }
 
+// appendVotes appends row to the file name in votesDir, writing header first when the file is new.
+// The files are append-only, so a row that does not have the columns of the existing header
+// is fatal rather than silently shifted into the wrong column.
+// TODO:return and handle error
+func appendVotes(name string, header, row []string) {
+	filename := filepath.Join(votesDir, name)
+	var osFile *os.File
+	_, err := os.Stat(filename)
+	var isCreate bool
+	if os.IsNotExist(err) {
+		// File doesn't exist, create it
+		osFile, err = os.Create(filename)
+		isCreate = true
+	} else {
+		// File exists, check its header and open it in append mode
+		err = checkHeader(filename, len(row))
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		osFile, err = os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0644)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer osFile.Close()
+
+	osWriter := csv.NewWriter(osFile)
+	defer osWriter.Flush()
+
+	if isCreate {
+		if err := osWriter.Write(header); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if err := osWriter.Write(row); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// checkHeader fails when the header of the CSV filename does not have columns columns.
+func checkHeader(filename string, columns int) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("error on open %s: %w", filename, err)
+	}
+	defer file.Close()
+
+	header, err := csv.NewReader(file).Read()
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error on read header of %s: %w", filename, err)
+	}
+
+	if len(header) != columns {
+		return fmt.Errorf("%s has %d columns, the row to append has %d: the candidates or parties changed", filename, len(header), columns)
+	}
+
+	return nil
+}
+
 func init() {
 	rootCmd.AddCommand(fetchVotesCmd)
 
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pararang/pemilu2024/kpu"
+	"github.com/spf13/cobra"
+)
+
+// votesDPDArchiveDir receives the raw responses of fetchVotesDPD, apart from the fetchVotes ones
+// so that replay only ever replays the runs of one command.
+const votesDPDArchiveDir = "output/raw/dpd"
+
+// fetchVotesDPDCmd represents the fetchVotesDPD command
+var fetchVotesDPDCmd = &cobra.Command{
+	Use:   "fetchVotesDPD",
+	Short: "fetch DPD votes",
+	Long:  "fetch DPD votes of every province from KPU and append them to the file(s)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fmt.Println("fetchVotesDPD called")
+
+		setupVotesRun(cmd, votesDPDArchiveDir)
+
+		sirekap := newSirekap()
+		defer logLimiterStats(sirekap)
+
+		return runFetchVotesDPD(cmd.Context(), sirekap)
+	},
+}
+
+// runFetchVotesDPD appends the current DPD votes of every province to the output files.
+func runFetchVotesDPD(ctx context.Context, sirekapClient kpu.Source) error {
+	var provinces kpu.Locations
+	err := sirekapClient.FetchLocations(ctx, &provinces, kpu.Nationwide)
+	if err != nil {
+		return err
+	}
+
+	for _, prov := range provinces {
+		// overseas voters do not elect a DPD
+		if prov.Code.IsOverseas() {
+			continue
+		}
+
+		votes, err := sirekapClient.GetVotesDPDByRegion(ctx, prov.Code)
+		if errors.Is(err, kpu.ErrNotFound) || errors.Is(err, kpu.ErrNotArchived) {
+			log.Printf("skip DPD votes of %s, not published yet: %v", prov.Name, err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		// the columns are the candidates on the ballot, there is no default to fall back to
+		candidates, err := sirekapClient.GetCandidatesDPD(ctx, prov.Code)
+		if errors.Is(err, kpu.ErrNotFound) || errors.Is(err, kpu.ErrNotArchived) {
+			log.Printf("skip DPD votes of %s, no candidates: %v", prov.Name, err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		saveVotesDPD(prov.Name, votes, candidates)
+	}
+
+	return nil
+}
+
+// saveVotesDPD appends the DPD votes of a province. The columns are the candidates of the province
+// in ballot order, taken from the master data rather than the chart, so a candidate not counted yet keeps its column.
+func saveVotesDPD(provName string, votes kpu.ResponseDataDPDRegion, candidates map[string]kpu.CandidateDPD) {
+	ids := kpu.BallotDPD(candidates)
+
+	header := []string{"ts", "created_at"}
+	for _, id := range ids {
+		label := candidates[id].Name
+		if label == "" {
+			label = id
+		}
+		header = append(header, label)
+	}
+
+	row := []string{
+		votes.Ts.String(), // WIB, created_at is UTC
+		timeProcessed.Format(time.RFC3339),
+	}
+	for _, id := range ids {
+		row = append(row, strconv.FormatInt(votes.Chart.Votes[id], 10))
+	}
+
+	appendVotes(strings.ReplaceAll(fmt.Sprintf("votes_dpd_0_%s.csv", strings.ToLower(provName)), " ", "_"), header, row)
+}
+
+func init() {
+	rootCmd.AddCommand(fetchVotesDPDCmd)
+}
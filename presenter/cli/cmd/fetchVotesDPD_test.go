@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pararang/pemilu2024/kpu"
+	"github.com/pararang/pemilu2024/kpu/kputest"
+)
+
+func TestRunFetchVotesDPD(t *testing.T) {
+	dir := inTempDir(t)
+
+	srv := kputest.NewSyntheticServer(kputest.DefaultTree)
+	defer srv.Close()
+
+	votesDir = dir
+	t.Cleanup(func() { votesDir = "output/votes" })
+
+	sirekap := srv.Sirekap()
+	ctx := context.Background()
+
+	// a second run appends a row under the header written by the first one
+	for i := 0; i < 2; i++ {
+		if err := runFetchVotesDPD(ctx, sirekap); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var provinces kpu.Locations
+	err := sirekap.FetchLocations(ctx, &provinces, kpu.Nationwide)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var checked int
+	for _, prov := range provinces {
+		if prov.Code.IsOverseas() {
+			continue
+		}
+
+		// the chart of the region has persen next to the votes
+		votes, err := sirekap.GetVotesDPDByRegion(ctx, prov.Code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if votes.Chart.Persen == nil {
+			t.Errorf("%s: chart without persen, the synthetic server should mix it in", prov.Name)
+		}
+
+		candidates, err := sirekap.GetCandidatesDPD(ctx, prov.Code)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		records := readCSV(t, filepath.Join(dir, "votes_dpd_0_"+strings.ReplaceAll(strings.ToLower(prov.Name), " ", "_")+".csv"))
+		if len(records) != 3 {
+			t.Fatalf("%s: %d rows, want a header and 2 runs", prov.Name, len(records))
+		}
+
+		// ts, created_at, then the candidates by ballot number
+		ids := kpu.BallotDPD(candidates)
+		for i, id := range ids {
+			if records[0][i+2] != candidates[id].Name {
+				t.Errorf("%s: column %d = %s, want %s", prov.Name, i+2, records[0][i+2], candidates[id].Name)
+			}
+		}
+
+		for _, record := range records[1:] {
+			if len(record) != len(ids)+2 || record[0] != votes.Ts.String() {
+				t.Fatalf("%s: row %v", prov.Name, record)
+			}
+			for i, id := range ids {
+				if record[i+2] != strconv.FormatInt(votes.Chart.Votes[id], 10) {
+					t.Errorf("%s: %s = %s, want %d", prov.Name, records[0][i+2], record[i+2], votes.Chart.Votes[id])
+				}
+			}
+		}
+		checked++
+	}
+
+	if checked == 0 {
+		t.Fatal("no province")
+	}
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pararang/pemilu2024/controller"
+	"github.com/pararang/pemilu2024/kpu"
+	"github.com/spf13/cobra"
+)
+
+// votesDPRDArchiveDir receives the raw responses of fetchVotesDPRD, see votesDPDArchiveDir.
+const votesDPRDArchiveDir = "output/raw/dprd"
+
+// fetchVotesDPRDCmd represents the fetchVotesDPRD command
+var fetchVotesDPRDCmd = &cobra.Command{
+	Use:   "fetchVotesDPRD",
+	Short: "fetch DPRD provinsi and kabupaten/kota votes",
+	Long:  "fetch DPRD provinsi votes of every province and DPRD kabupaten/kota votes of every regency from KPU and append them to the file(s), per administrative region, not per DPRD dapil",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fmt.Println("fetchVotesDPRD called")
+
+		setupVotesRun(cmd, votesDPRDArchiveDir)
+
+		sirekap := newSirekap()
+		defer logLimiterStats(sirekap)
+
+		return runFetchVotesDPRD(cmd.Context(), sirekap)
+	},
+}
+
+// runFetchVotesDPRD appends the current DPRD votes of every province and regency to the output files.
+func runFetchVotesDPRD(ctx context.Context, sirekapClient kpu.Source) error {
+	var provinces kpu.Locations
+	err := sirekapClient.FetchLocations(ctx, &provinces, kpu.Nationwide)
+	if err != nil {
+		return err
+	}
+
+	parties, err := fetchParties(ctx, sirekapClient)
+	if err != nil {
+		return err
+	}
+
+	controller := controller.NewController(sirekapClient)
+	for _, prov := range provinces {
+		// overseas voters do not elect a DPRD
+		if prov.Code.IsOverseas() {
+			continue
+		}
+
+		votes, err := controller.GetVotesRegion(ctx, kpu.DPRDProvince, prov.Code)
+		if errors.Is(err, kpu.ErrNotFound) || errors.Is(err, kpu.ErrNotArchived) {
+			log.Printf("skip DPRD provinsi votes of %s, not published yet: %v", prov.Name, err)
+		} else if err != nil {
+			return err
+		} else {
+			saveVotesDPRD(fmt.Sprintf("votes_dprdp_0_%s.csv", prov.Name), votes, parties, prov.Code == kpu.Aceh)
+		}
+
+		var cities kpu.Locations
+		err = sirekapClient.FetchLocations(ctx, &cities, prov.Code)
+		if err != nil {
+			return err
+		}
+
+		for _, city := range cities {
+			votes, err := controller.GetVotesRegion(ctx, kpu.DPRDRegency, city.Code)
+			if errors.Is(err, kpu.ErrNotFound) || errors.Is(err, kpu.ErrNotArchived) {
+				log.Printf("skip DPRD kabupaten/kota votes of %s, not published yet: %v", city.Name, err)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			saveVotesDPRD(fmt.Sprintf("votes_dprdk_0_%s_%s.csv", prov.Name, city.Name), votes, parties, prov.Code == kpu.Aceh)
+		}
+	}
+
+	return nil
+}
+
+// saveVotesDPRD appends the votes of a region to the file name. The columns are the parties on the ballot
+// of the region, taken from the registry rather than the chart, so a null or missing party keeps its column.
+func saveVotesDPRD(name string, votes kpu.ResponseDataRegion, parties kpu.PartyRegistry, aceh bool) {
+	ids := parties.Ballot(aceh)
+
+	header := []string{"ts", "created_at"}
+	for _, id := range ids {
+		header = append(header, parties.Label(id))
+	}
+
+	row := []string{
+		votes.Ts.String(), // WIB, created_at is UTC
+		timeProcessed.Format(time.RFC3339),
+	}
+	for _, id := range ids {
+		row = append(row, strconv.FormatInt(votes.Chart.Votes[id], 10))
+	}
+
+	appendVotes(strings.ReplaceAll(strings.ToLower(name), " ", "_"), header, row)
+}
+
+func init() {
+	rootCmd.AddCommand(fetchVotesDPRDCmd)
+}
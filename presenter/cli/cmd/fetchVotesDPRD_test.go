@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pararang/pemilu2024/kpu"
+	"github.com/pararang/pemilu2024/kpu/kputest"
+)
+
+func TestRunFetchVotesDPRD(t *testing.T) {
+	dir := inTempDir(t)
+
+	srv := kputest.NewSyntheticServer(kputest.DefaultTree)
+	defer srv.Close()
+
+	votesDir = dir
+	t.Cleanup(func() { votesDir = "output/votes" })
+
+	sirekap := srv.Sirekap()
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if err := runFetchVotesDPRD(ctx, sirekap); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	parties, err := sirekap.GetParties(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var provinces kpu.Locations
+	err = sirekap.FetchLocations(ctx, &provinces, kpu.Nationwide)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fileName := func(format string, names ...any) string {
+		return filepath.Join(dir, strings.ReplaceAll(strings.ToLower(fmt.Sprintf(format, names...)), " ", "_"))
+	}
+
+	var acehChecked bool
+	for _, prov := range provinces {
+		if prov.Code.IsOverseas() {
+			continue
+		}
+
+		// the local parties of Aceh only run for its DPRD
+		aceh := prov.Code == kpu.Aceh
+		ids := parties.Ballot(aceh)
+		if aceh {
+			acehChecked = true
+			if len(ids) != 24 {
+				t.Errorf("Aceh ballot of %d parties, want 18 national and 6 local ones", len(ids))
+			}
+		}
+
+		votes, err := sirekap.GetVotesRegion(ctx, kpu.DPRDProvince, prov.Code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkDPRD(t, fileName("votes_dprdp_0_%s.csv", prov.Name), votes, parties, ids)
+
+		var cities kpu.Locations
+		err = sirekap.FetchLocations(ctx, &cities, prov.Code)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, city := range cities {
+			votes, err := sirekap.GetVotesRegion(ctx, kpu.DPRDRegency, city.Code)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkDPRD(t, fileName("votes_dprdk_0_%s_%s.csv", prov.Name, city.Name), votes, parties, ids)
+		}
+	}
+
+	if !acehChecked {
+		t.Fatal("no Aceh in the synthetic tree")
+	}
+}
+
+// checkDPRD checks the file of a region has the ballot ids as columns and two runs of votes.
+func checkDPRD(t *testing.T, name string, votes kpu.ResponseDataRegion, parties kpu.PartyRegistry, ids []string) {
+	t.Helper()
+
+	records := readCSV(t, name)
+	if len(records) != 3 {
+		t.Fatalf("%s: %d rows, want a header and 2 runs", name, len(records))
+	}
+
+	for i, id := range ids {
+		if records[0][i+2] != parties.Label(id) {
+			t.Errorf("%s: column %d = %s, want %s", name, i+2, records[0][i+2], parties.Label(id))
+		}
+	}
+
+	for _, record := range records[1:] {
+		if len(record) != len(ids)+2 {
+			t.Fatalf("%s: row of %d columns, want %d", name, len(record), len(ids)+2)
+		}
+		for i, id := range ids {
+			if record[i+2] != strconv.FormatInt(votes.Chart.Votes[id], 10) {
+				t.Errorf("%s: %s = %s, want %d", name, records[0][i+2], record[i+2], votes.Chart.Votes[id])
+			}
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pararang/pemilu2024/kpu"
+	"github.com/spf13/cobra"
+)
+
+const archiveTimeLayout = "20060102-150405"
+
+var replayArchiveDir string
+var replayAt string
+var replayAll bool
+var replayOutputDir string
+
+// replayVotesDir is the default output of replay, apart from the history the fetch commands append to.
+const replayVotesDir = "output/replay"
+
+// replayCmd represents the replay command
+var replayCmd = &cobra.Command{
+	Use:       "replay [fetchVotes|fetchVotesDPD|fetchVotesDPRD|fetchLocations]",
+	Short:     "re-run a fetch command against archived KPU responses",
+	Long:      "re-run fetchVotes, fetchVotesDPD, fetchVotesDPRD or fetchLocations against the raw responses archived by previous runs instead of calling KPU",
+	Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	ValidArgs: []string{"fetchVotes", "fetchVotesDPD", "fetchVotesDPRD", "fetchLocations"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fmt.Printf("replay %s called\n", args[0])
+
+		dir := replayArchiveDir
+		if dir == "" {
+			dir = commandArchiveDir(args[0])
+		}
+
+		archives, err := listArchives(dir)
+		if err != nil {
+			return err
+		}
+
+		if len(archives) == 0 {
+			return fmt.Errorf("no archive found in %s", dir)
+		}
+
+		votesDir = replayOutputDir
+		locationsDir = replayOutputDir
+
+		// the votes files are append-only: replaying into files that already have rows duplicates them,
+		// and the location files of --staticFileName would be overwritten
+		csvFiles, err := filepath.Glob(filepath.Join(votesDir, "*.csv"))
+		if err != nil {
+			return err
+		}
+		if len(csvFiles) > 0 {
+			return fmt.Errorf("%s already has %d CSV files, replay into an empty --outputDir", votesDir, len(csvFiles))
+		}
+
+		err = os.MkdirAll(votesDir, 0755)
+		if err != nil {
+			return err
+		}
+
+		var at time.Time
+		if replayAt != "" {
+			at, err = time.Parse(time.RFC3339, replayAt)
+			if err != nil {
+				return fmt.Errorf("invalid --at, expect RFC3339: %w", err)
+			}
+		}
+
+		replay := kpu.NewReplayTransport()
+		sirekap := kpu.NewSirekap(&http.Client{Transport: replay},
+			kpu.WithRetry(kpu.NoRetry),
+			kpu.WithRateLimit(kpu.RateLimit{}),
+		)
+
+		run := func() error {
+			switch args[0] {
+			case "fetchVotes":
+				return runFetchVotes(cmd.Context(), sirekap, replay.NotModified)
+			case "fetchVotesDPD":
+				return runFetchVotesDPD(cmd.Context(), sirekap)
+			case "fetchVotesDPRD":
+				return runFetchVotesDPRD(cmd.Context(), sirekap)
+			default:
+				return runFetchLocations(cmd.Context(), sirekap)
+			}
+		}
+
+		// every archive is one past run: replaying them one after the other, each on top of
+		// the responses known so far, rebuilds the output history row by row
+		for _, archive := range archives {
+			runAt, err := archiveTime(archive)
+			if err != nil {
+				return err
+			}
+
+			if !at.IsZero() && runAt.After(at) {
+				break
+			}
+
+			err = replay.LoadArchive(archive, at)
+			if err != nil {
+				return err
+			}
+
+			timeProcessed = runAt
+
+			if replayAll {
+				log.Printf("replay run of %s\n", runAt.Format(time.RFC3339))
+				if err := run(); err != nil {
+					return err
+				}
+			}
+		}
+
+		if replayAll {
+			return nil
+		}
+
+		log.Printf("replay as of %s, %d archived responses\n", timeProcessed.Format(time.RFC3339), replay.Len())
+		return run()
+	},
+}
+
+// commandArchiveDir is the directory the command archives its runs to by default. Each command has
+// its own: the archive of a run only holds the responses that command fetched.
+func commandArchiveDir(command string) string {
+	switch command {
+	case "fetchVotesDPD":
+		return votesDPDArchiveDir
+	case "fetchVotesDPRD":
+		return votesDPRDArchiveDir
+	case "fetchLocations":
+		return locationsArchiveDir
+	default:
+		return votesArchiveDir
+	}
+}
+
+// listArchives returns the archives written by previous runs, oldest first.
+func listArchives(dir string) ([]string, error) {
+	archives, err := filepath.Glob(filepath.Join(dir, "sirekap_*.jsonl.gz"))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(archives)
+
+	return archives, nil
+}
+
+// archiveTime returns the time the run that wrote archive started, as encoded in its name.
+func archiveTime(archive string) (time.Time, error) {
+	name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(archive), "sirekap_"), ".jsonl.gz")
+
+	runAt, err := time.Parse(archiveTimeLayout, name)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unexpected archive name %s: %w", archive, err)
+	}
+
+	return runAt, nil
+}
+
+func init() {
+	rootCmd.AddCommand(replayCmd)
+
+	replayCmd.Flags().StringVar(&replayArchiveDir, "archives", "", "directory of the archives to replay, default to the archive directory of the command")
+	replayCmd.Flags().StringVar(&replayAt, "at", "", "replay the responses as they were at this RFC3339 time, default to the latest")
+	replayCmd.Flags().BoolVar(&replayAll, "all", false, "replay every archived run in order instead of only the last one")
+	replayCmd.Flags().StringVar(&replayOutputDir, "outputDir", replayVotesDir, "empty directory receiving the replayed votes or locations")
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pararang/pemilu2024/kpu"
+	"github.com/pararang/pemilu2024/kpu/kputest"
+)
+
+// recordRun runs fn against the synthetic server like a live run archiving to dir, writing its files to output.
+func recordRun(t *testing.T, dir, output string, fn func(sirekap *kpu.Sirekap) error) {
+	t.Helper()
+
+	srv := kputest.NewSyntheticServer(kputest.DefaultTree)
+	defer srv.Close()
+
+	archive, err := kpu.CreateArchive(filepath.Join(dir, "sirekap_"+timeProcessed.Format(archiveTimeLayout)+".jsonl.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	votesDir, locationsDir = output, output
+	err = os.MkdirAll(output, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fn(srv.Sirekap(kpu.WithArchive(archive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = archive.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// replayRun replays command from its default archive directory into output, without any server.
+func replayRun(command, output string) error {
+	replayArchiveDir, replayOutputDir, replayAt, replayAll = "", output, "", false
+	replayCmd.SetContext(context.Background())
+
+	return replayCmd.RunE(replayCmd, []string{command})
+}
+
+// sameFiles fails unless every file of want is in got with the same content.
+func sameFiles(t *testing.T, want, got string) {
+	t.Helper()
+
+	entries, err := os.ReadDir(want)
+	if err != nil || len(entries) == 0 {
+		t.Fatalf("nothing written to %s: %v", want, err)
+	}
+
+	for _, entry := range entries {
+		wantData, err := os.ReadFile(filepath.Join(want, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		gotData, err := os.ReadFile(filepath.Join(got, entry.Name()))
+		if err != nil {
+			t.Errorf("not replayed: %v", err)
+			continue
+		}
+
+		if string(gotData) != string(wantData) {
+			t.Errorf("%s: replayed\n%s\nwant\n%s", entry.Name(), gotData, wantData)
+		}
+	}
+}
+
+func resetReplay(t *testing.T) {
+	processed := timeProcessed
+	t.Cleanup(func() {
+		timeProcessed = processed
+		votesDir, locationsDir = "output/votes", ""
+		replayArchiveDir, replayOutputDir = "", replayVotesDir
+	})
+
+	// the archive name only keeps the seconds
+	timeProcessed = time.Date(2024, 2, 25, 9, 0, 16, 0, time.UTC)
+}
+
+func TestReplayFetchVotes(t *testing.T) {
+	inTempDir(t)
+	resetReplay(t)
+
+	recordRun(t, votesArchiveDir, "live", func(sirekap *kpu.Sirekap) error {
+		return runFetchVotes(context.Background(), sirekap, func(string) bool { return false })
+	})
+
+	err := replayRun("fetchVotes", "replayed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameFiles(t, "live", "replayed")
+
+	// replaying again would append every row a second time
+	err = replayRun("fetchVotes", "replayed")
+	if err == nil {
+		t.Error("replay into a directory with CSV files: want an error")
+	}
+
+	// the DPD runs are archived apart, none was recorded
+	err = replayRun("fetchVotesDPD", "dpd")
+	if err == nil {
+		t.Error("replay fetchVotesDPD without archive: want an error")
+	}
+}
+
+func TestReplayFetchLocations(t *testing.T) {
+	inTempDir(t)
+	resetReplay(t)
+
+	fileType, staticFileName, locationDepth = "csv", true, kpu.LevelVillage
+	t.Cleanup(func() { fileType, staticFileName = "", false })
+
+	recordRun(t, locationsArchiveDir, "live", func(sirekap *kpu.Sirekap) error {
+		return runFetchLocations(context.Background(), sirekap)
+	})
+
+	err := replayRun("fetchLocations", "replayed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameFiles(t, "live", "replayed")
+
+	// the live files of --staticFileName in the working directory are left alone
+	if _, err := os.Stat("indonesia_location.csv"); !os.IsNotExist(err) {
+		t.Errorf("replay wrote to the working directory: %v", err)
+	}
+}
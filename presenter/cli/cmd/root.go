@@ -4,36 +4,183 @@ Copyright © 2024 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
-	"crypto/tls"
+	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
 	"time"
 
+	"github.com/pararang/pemilu2024/kpu"
 	"github.com/spf13/cobra"
 )
 
 var fileType string
 var staticFileName bool
 var maxLoop uint
+var retryMaxAttempts int
+var rateLimit float64
+var rateBurst int
+var requestTimeout time.Duration
 
 var timeProcessed = time.Now().UTC()
+var transportConfig = kpu.DefaultTransportConfig
 var stdHttpClient *http.Client
+var plainHttpClient *http.Client
+var conditionalTransport *kpu.ConditionalTransport
+var conditionalCache string
+var noCache bool
+var purgeCache bool
+var diskCacheConfig = kpu.DefaultDiskCacheConfig
+var archiveDir string
+var archive *kpu.ArchiveWriter
+var strict bool
+var driftReportFile string
+var driftReport *kpu.DriftReport
 
 type loggingTransport struct {
 	Transport http.RoundTripper
 }
 
 func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var retry string
+	if attempt := kpu.Attempt(req.Context()); attempt > 1 {
+		retry = fmt.Sprintf(" retry #%d", attempt-1)
+	}
+
 	resp, err := t.Transport.RoundTrip(req)
 	if err != nil {
+		log.Printf("HTTP Request %s %s%s [%s]\n", req.Method, req.URL.String(), retry, err)
 		return nil, err
 	}
 
-	log.Printf("HTTP Request %s %s [%s]\n", req.Method, req.URL.String(), resp.Status)
+	log.Printf("HTTP Request %s %s%s [%s]\n", req.Method, req.URL.String(), retry, resp.Status)
 	return resp, nil
 }
 
+func newSirekap() *kpu.Sirekap {
+	opts := requestOptions()
+
+	if !noCache {
+		cache, err := kpu.OpenDiskCache(diskCacheConfig)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if purgeCache {
+			if err := cache.Purge(); err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		entries, size := cache.Size()
+		log.Printf("disk cache %s: %d responses, %d bytes\n", diskCacheConfig.Dir, entries, size)
+
+		opts = append(opts, kpu.WithDiskCache(cache))
+	}
+
+	if archiveDir != "" {
+		var err error
+		archive, err = kpu.CreateArchive(filepath.Join(archiveDir, fmt.Sprintf("sirekap_%s.jsonl.gz", timeProcessed.Format(archiveTimeLayout))))
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		opts = append(opts, kpu.WithArchive(archive))
+	}
+
+	if strict {
+		if driftReport == nil {
+			driftReport = kpu.NewDriftReport()
+		}
+
+		opts = append(opts, kpu.WithDriftReport(driftReport))
+	}
+
+	return kpu.NewSirekap(stdHttpClient, opts...)
+}
+
+// newFileSirekap is the client of binary downloads, e.g. the C1 scans. It only has the retry policy,
+// rate limit and timeout of the flags: the conditional and disk caches and the archive would keep
+// every file in memory or in the JSON evidence, and there is nothing to decode for the strict mode.
+func newFileSirekap() *kpu.Sirekap {
+	return kpu.NewSirekap(plainHttpClient, requestOptions()...)
+}
+
+// requestOptions applies the flags shared by every client.
+func requestOptions() []kpu.Option {
+	retry := kpu.DefaultRetryPolicy
+	retry.MaxAttempts = retryMaxAttempts
+
+	return []kpu.Option{
+		kpu.WithRetry(retry),
+		kpu.WithRateLimit(kpu.RateLimit{PerSecond: rateLimit, Burst: rateBurst}),
+		kpu.WithRequestTimeout(requestTimeout),
+	}
+}
+
+func logLimiterStats(sirekap *kpu.Sirekap) {
+	stats := sirekap.LimiterStats()
+	log.Printf("rate limiter: %d requests, %d delayed, waited %s in total\n", stats.Requests, stats.Delayed, stats.Waited)
+}
+
+// defaultConditionalCache is the conditional cache of fetchVotes. The other commands have none unless
+// --conditionalCache is set: the cache keeps every body in memory and in a single file, which a crawl
+// of the locations would make unbounded.
+const defaultConditionalCache = ".cache/conditional.json"
+
+// setupHttpClients builds the shared transport once the flags are parsed. Only a run with a conditional
+// cache file goes through ConditionalTransport, conditionalTransport is nil otherwise.
+func setupHttpClients() error {
+	if transportConfig.InsecureSkipVerify {
+		log.Println("WARNING TLS verification of KPU is disabled, responses can be tampered with")
+	}
+
+	transport, err := kpu.NewTransport(transportConfig)
+	if err != nil {
+		return err
+	}
+
+	// binary downloads skip the conditional cache, which keeps every body in memory and in its file
+	plainHttpClient = &http.Client{
+		Transport: &loggingTransport{Transport: transport},
+	}
+
+	if conditionalCache == "" {
+		stdHttpClient = plainHttpClient
+		return nil
+	}
+
+	conditionalTransport = kpu.NewConditionalTransport(&loggingTransport{
+		Transport: transport,
+	})
+
+	stdHttpClient = &http.Client{
+		Transport: conditionalTransport,
+	}
+
+	return conditionalTransport.Load(conditionalCache)
+}
+
+// saveDriftReport writes the drift report of the run and warns when Sirekap changed its contract.
+func saveDriftReport() error {
+	if err := driftReport.Save(driftReportFile); err != nil {
+		return err
+	}
+
+	drifts := driftReport.Drifts()
+	if len(drifts) > 0 {
+		log.Printf("WARNING schema drift: %d differences in %d responses, see %s\n", len(drifts), driftReport.Responses(), driftReportFile)
+	} else {
+		log.Printf("schema drift: none in %d responses\n", driftReport.Responses())
+	}
+
+	return nil
+}
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "cli",
@@ -47,12 +194,53 @@ to quickly create a Cobra application.`,
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if cmd == fetchVotesCmd && !cmd.Flags().Changed("conditionalCache") {
+			conditionalCache = defaultConditionalCache
+		}
+
+		return setupHttpClients()
+	},
+	// a failed run is not a usage error
+	SilenceUsage: true,
+}
+
+// finishRun closes the archive and saves the reports and the conditional cache of the run. Execute calls it
+// whatever the outcome of the command, so that an interrupted run still leaves them behind.
+func finishRun() error {
+	if driftReport != nil {
+		if err := saveDriftReport(); err != nil {
+			return err
+		}
+	}
+
+	if archive != nil {
+		if err := archive.Close(); err != nil {
+			return err
+		}
+	}
+
+	if conditionalCache == "" || conditionalTransport == nil {
+		return nil
+	}
+
+	return conditionalTransport.Save(conditionalCache)
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	err := rootCmd.Execute()
+	// Ctrl-C or a cancelled job stops the in-flight requests instead of crawling on
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := rootCmd.ExecuteContext(ctx)
+
+	if errFinish := finishRun(); errFinish != nil {
+		log.Println(errFinish)
+		err = errFinish
+	}
+
 	if err != nil {
 		os.Exit(1)
 	}
@@ -62,17 +250,27 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&fileType, "fileType", "", "file type i/o")
 	rootCmd.PersistentFlags().BoolVar(&staticFileName, "staticFileName", false, "use static file name on output file")
 	rootCmd.PersistentFlags().UintVar(&maxLoop, "maxLoop", 0, "max loop")
+	rootCmd.PersistentFlags().IntVar(&retryMaxAttempts, "retryMaxAttempts", kpu.DefaultRetryPolicy.MaxAttempts, "max attempts per KPU request, 1 to disable retry")
+	rootCmd.PersistentFlags().Float64Var(&rateLimit, "rateLimit", kpu.DefaultRateLimit.PerSecond, "max KPU requests per second, 0 to disable")
+	rootCmd.PersistentFlags().IntVar(&rateBurst, "rateBurst", kpu.DefaultRateLimit.Burst, "max KPU requests at once before rateLimit applies")
+	rootCmd.PersistentFlags().DurationVar(&requestTimeout, "requestTimeout", time.Minute, "timeout of a single KPU request attempt, 0 to disable")
+	rootCmd.PersistentFlags().StringVar(&conditionalCache, "conditionalCache", "", "file keeping ETag/Last-Modified and bodies of KPU responses between runs, fetchVotes defaults to "+defaultConditionalCache)
+	rootCmd.PersistentFlags().BoolVar(&noCache, "noCache", false, "bypass the on-disk response cache")
+	rootCmd.PersistentFlags().BoolVar(&purgeCache, "purgeCache", false, "empty the on-disk response cache before running")
+	rootCmd.PersistentFlags().StringVar(&diskCacheConfig.Dir, "cacheDir", diskCacheConfig.Dir, "directory of the on-disk response cache")
+	rootCmd.PersistentFlags().DurationVar(&diskCacheConfig.LocationTTL, "cacheLocationTTL", diskCacheConfig.LocationTTL, "how long cached location lists stay fresh")
+	rootCmd.PersistentFlags().DurationVar(&diskCacheConfig.VoteTTL, "cacheVoteTTL", diskCacheConfig.VoteTTL, "how long cached votes stay fresh, the fetchVotes commands bypass the cache unless set")
+	rootCmd.PersistentFlags().Int64Var(&diskCacheConfig.MaxBytes, "cacheMaxBytes", diskCacheConfig.MaxBytes, "size cap of the on-disk response cache, 0 for no cap")
+	rootCmd.PersistentFlags().BoolVar(&strict, "strict", false, "compare every KPU response with the expected schema and report unknown, missing and mistyped fields, without failing the run")
+	rootCmd.PersistentFlags().StringVar(&driftReportFile, "driftReport", "drift_report.json", "file receiving the schema drift report of --strict")
+	rootCmd.PersistentFlags().StringVar(&archiveDir, "archiveDir", "", "directory receiving a compressed archive of every raw KPU response of this run, empty to disable")
 
-	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	}
-
-	stdHttpClient = &http.Client{
-		Transport: &loggingTransport{
-			Transport: transport,
-		},
-	}
-
+	rootCmd.PersistentFlags().BoolVar(&transportConfig.InsecureSkipVerify, "insecureSkipVerify", false, "do not verify the TLS certificate of KPU, only to work around a broken chain")
+	rootCmd.PersistentFlags().StringVar(&transportConfig.CAFile, "caFile", "", "PEM bundle of extra trusted CA certificates")
+	rootCmd.PersistentFlags().StringVar(&transportConfig.Proxy, "proxy", "", "HTTP(S) proxy URL, empty to use HTTPS_PROXY from the environment")
+	rootCmd.PersistentFlags().DurationVar(&transportConfig.DialTimeout, "dialTimeout", transportConfig.DialTimeout, "timeout of connecting to KPU")
+	rootCmd.PersistentFlags().DurationVar(&transportConfig.TLSHandshakeTimeout, "tlsHandshakeTimeout", transportConfig.TLSHandshakeTimeout, "timeout of the TLS handshake with KPU")
+	rootCmd.PersistentFlags().DurationVar(&transportConfig.ResponseHeaderTimeout, "responseHeaderTimeout", transportConfig.ResponseHeaderTimeout, "timeout of waiting for the response headers of KPU")
 
 	// Here you will define your flags and configuration settings.
 	// Cobra supports persistent flags, which, if defined here,
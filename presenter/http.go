@@ -2,7 +2,10 @@ package presenter
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/pararang/pemilu2024/controller"
 	"github.com/pararang/pemilu2024/kpu"
@@ -12,16 +15,22 @@ type Handler struct {
 	control     *controller.Controller
 }
 
-func NewPresenterHTTP(sirekap *kpu.Sirekap) *Handler {
+func NewPresenterHTTP(sirekap kpu.Source) *Handler {
 	return &Handler{
 		control: controller.NewController(sirekap),
 	}
 }
 
 func (h *Handler) GetVotes(w http.ResponseWriter, r *http.Request) {
-	data, err := h.control.GetVotes(r.URL.Query().Get("tps"))
+	code, err := kpu.ParseCode(r.URL.Query().Get("tps"))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	data, err := h.control.GetVotes(r.Context(), code)
+	if err != nil {
+		http.Error(w, err.Error(), statusCode(err))
 		return
 	}
 
@@ -32,13 +41,132 @@ func (h *Handler) GetVotes(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(data)
 }
 
+func (h *Handler) GetVotesByRegion(w http.ResponseWriter, r *http.Request) {
+	code, err := kpu.ParseCode(r.URL.Query().Get("code"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	data, err := h.control.GetVotesByRegion(r.Context(), code)
+	if err != nil {
+		http.Error(w, err.Error(), statusCode(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data)
+}
+
+func (h *Handler) GetVotesLegislative(w http.ResponseWriter, r *http.Request) {
+	code, err := kpu.ParseCode(r.URL.Query().Get("tps"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	data, err := h.control.GetVotesLegislativeByTPS(r.Context(), code)
+	if err != nil {
+		http.Error(w, err.Error(), statusCode(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data)
+}
+
+func (h *Handler) GetVotesLegislativeByRegion(w http.ResponseWriter, r *http.Request) {
+	code, err := kpu.ParseCode(r.URL.Query().Get("code"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	data, err := h.control.GetVotesLegislativeByRegion(r.Context(), code)
+	if err != nil {
+		http.Error(w, err.Error(), statusCode(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data)
+}
+
+// GetCandidatesDPRByDapil returns the ranked DPR caleg of the dapil in ?dapil, e.g. ?dapil=7301.
+func (h *Handler) GetCandidatesDPRByDapil(w http.ResponseWriter, r *http.Request) {
+	data, err := h.control.GetCandidatesDPRByDapil(r.Context(), r.URL.Query().Get("dapil"))
+	if err != nil {
+		http.Error(w, err.Error(), statusCode(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data)
+}
+
 func (h *Handler) GetLocations(w http.ResponseWriter, r *http.Request) {
-	locations, err := h.control.GetLocations(0)
+	depth, err := depthParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	locations, err := h.control.GetLocations(r.Context(), 0, depth)
+	if err != nil {
+		http.Error(w, err.Error(), statusCode(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(locations)
+}
+
+// GetLocationsOverseas returns the Luar Negeri branch, ?depth like GetLocations.
+func (h *Handler) GetLocationsOverseas(w http.ResponseWriter, r *http.Request) {
+	depth, err := depthParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	locations, err := h.control.GetLocationsOverseas(r.Context(), depth)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), statusCode(err))
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(locations)
 }
+
+// depthParam reads ?depth, the deepest location level to crawl. ?depth=5 includes the TPS,
+// the default stops at the villages.
+func depthParam(r *http.Request) (int, error) {
+	raw := r.URL.Query().Get("depth")
+	if raw == "" {
+		return kpu.LevelVillage, nil
+	}
+
+	depth, err := strconv.Atoi(raw)
+	if err != nil || depth < kpu.LevelProvince || depth > kpu.LevelTPS {
+		return 0, fmt.Errorf("invalid depth %q, expect %d to %d", raw, kpu.LevelProvince, kpu.LevelTPS)
+	}
+
+	return depth, nil
+}
+
+// statusCode maps upstream Sirekap failures to the status returned to our own clients.
+func statusCode(err error) int {
+	switch {
+	case errors.Is(err, kpu.ErrInvalidCode):
+		return http.StatusBadRequest
+	case errors.Is(err, kpu.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, kpu.ErrRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, kpu.ErrUnavailable), errors.Is(err, kpu.ErrMalformedBody):
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}